@@ -0,0 +1,82 @@
+package disorder
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	codecsMap      = make(map[reflect.Type]*codec)
+	codecsMapMutex sync.RWMutex
+)
+
+// EncodeFunc writes value, which holds a value of the registered type, under name
+// with the Write methods of the encoder.
+type EncodeFunc func(e *Encoder, name string, value interface{}) error
+
+// DecodeFunc reads the current value of the decoder into value, which is a pointer to
+// the registered type, with the Read methods of the decoder. Null is handled before it is called.
+type DecodeFunc func(d *Decoder, value interface{}) error
+
+type codec struct {
+	encode EncodeFunc
+	decode DecodeFunc
+}
+
+// RegisterCodec maps typ onto existing wire types for all encoders and decoders,
+// for types which cannot implement Marshaler and Unmarshaler themselves.
+// Either function may be nil to only register one direction. Registered codecs are
+// consulted before any interface the type implements and before reflection.
+func RegisterCodec(typ reflect.Type, encode EncodeFunc, decode DecodeFunc) {
+	codecsMapMutex.Lock()
+	codecsMap[typ] = &codec{
+		encode: encode,
+		decode: decode,
+	}
+	codecsMapMutex.Unlock()
+}
+
+// RegisterEncoder overrides the registered codec of typ for this encoder only,
+// a nil function encodes typ as if no codec was registered.
+func (e *Encoder) RegisterEncoder(typ reflect.Type, encode EncodeFunc) {
+	if e.encoders == nil {
+		e.encoders = make(map[reflect.Type]EncodeFunc)
+	}
+	e.encoders[typ] = encode
+}
+
+// RegisterDecoder overrides the registered codec of typ for this decoder only,
+// a nil function decodes typ as if no codec was registered.
+func (d *Decoder) RegisterDecoder(typ reflect.Type, decode DecodeFunc) {
+	if d.decoders == nil {
+		d.decoders = make(map[reflect.Type]DecodeFunc)
+	}
+	d.decoders[typ] = decode
+}
+
+func getCodec(typ reflect.Type) *codec {
+	codecsMapMutex.RLock()
+	c := codecsMap[typ]
+	codecsMapMutex.RUnlock()
+	return c
+}
+
+func (e *Encoder) encodeFunc(typ reflect.Type) EncodeFunc {
+	if encode, ok := e.encoders[typ]; ok {
+		return encode
+	}
+	if c := getCodec(typ); c != nil {
+		return c.encode
+	}
+	return nil
+}
+
+func (d *Decoder) decodeFunc(typ reflect.Type) DecodeFunc {
+	if decode, ok := d.decoders[typ]; ok {
+		return decode
+	}
+	if c := getCodec(typ); c != nil {
+		return c.decode
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package disorder_test
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
+	"net"
+	"strings"
 	"time"
+
+	"github.com/meerkat-io/disorder"
 )
 
 type Color struct {
@@ -71,10 +77,72 @@ type Object struct {
 	Nested       map[string]map[string][][]map[string]*Color `disorder:"nested" json:"nested,omitempty"`
 }
 
+// newObject returns an Object with all kinds of fields set.
+func newObject() Object {
+	timestamp := time.UnixMilli(time.Now().UnixMilli())
+	return Object{
+		BooleanField: true,
+		IntField:     123,
+		StringField:  "foo",
+		BytesFields:  []byte{7, 8, 9},
+		EnumField:    &ColorBlue,
+		TimeField:    &timestamp,
+		ObjField: &NumberWrapper{
+			Value: &Number{
+				Value: 789,
+			},
+		},
+		IntArray: []int32{1, 2, 3},
+		IntMap: map[string]int32{
+			"4": 4,
+			"5": 5,
+			"6": 6,
+		},
+		ObjArray: []*NumberWrapper{{Value: &Number{
+			Value: 789,
+		}}},
+		ObjMap: map[string]*NumberWrapper{
+			"789": {Value: &Number{
+				Value: 789,
+			}},
+		},
+		Nested: map[string]map[string][][]map[string]*Color{
+			"key0": {
+				"key1": {
+					{
+						{
+							"key2": &ColorBlue,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
 type Number struct {
 	Value int32 `disorder:"value" json:"value"`
 }
 
+// Rows streams Count numbers as an array, like rows read from a database cursor.
+type Rows struct {
+	Count int
+}
+
+func (r *Rows) MarshalDisorder(e *disorder.Encoder, name string) error {
+	err := e.BeginArray(name)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < r.Count; i++ {
+		err = e.WriteValue("", &Number{Value: int32(i)})
+		if err != nil {
+			return err
+		}
+	}
+	return e.EndArray()
+}
+
 type NumberWrapper struct {
 	Value *Number `disorder:"value" json:"value,omitempty"`
 }
@@ -87,3 +155,284 @@ type Zero struct {
 	ZeroArray []int32          `disorder:"zero_array" json:"zero_array,omitempty"`
 	ZeroMap   map[string]int32 `disorder:"zero_map" json:"zero_map,omitempty"`
 }
+
+type Integers struct {
+	Int     int     `disorder:"int"`
+	Int8    int8    `disorder:"int8"`
+	Int16   int16   `disorder:"int16"`
+	Uint    uint    `disorder:"uint"`
+	Uint8   uint8   `disorder:"uint8"`
+	Uint16  uint16  `disorder:"uint16"`
+	Uint32  uint32  `disorder:"uint32"`
+	Uint64  uint64  `disorder:"uint64"`
+	Uintptr uintptr `disorder:"uintptr"`
+}
+
+type Point struct {
+	X int32
+	Y int32
+}
+
+func (p *Point) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteObject(name, func() error {
+		if err := e.WriteInt("x", p.X); err != nil {
+			return err
+		}
+		return e.WriteInt("y", p.Y)
+	})
+}
+
+func (p *Point) UnmarshalDisorder(d *disorder.Decoder) error {
+	return d.ReadObject(func(key string) error {
+		switch key {
+		case "x":
+			return d.ReadInt(&p.X)
+		case "y":
+			return d.ReadInt(&p.Y)
+		default:
+			return d.SkipUnknown(key, p)
+		}
+	})
+}
+
+type Times struct {
+	Time      time.Time            `disorder:"time"`
+	TimeArray []time.Time          `disorder:"time_array"`
+	TimeMap   map[string]time.Time `disorder:"time_map"`
+	Zero      time.Time            `disorder:"zero"`
+}
+
+type Options struct {
+	Count    int32            `disorder:"count,omitempty"`
+	Name     string           `disorder:"name,omitempty"`
+	Tags     []string         `disorder:"tags,omitempty"`
+	Labels   map[string]int32 `disorder:"labels,omitempty"`
+	Parent   *Base            `disorder:"parent,required"`
+	Key      string           `disorder:"key,required"`
+	Price    float64          `disorder:"price,string"`
+	Enabled  bool             `disorder:"enabled,string"`
+	Quantity *uint16          `disorder:"quantity,string,omitempty"`
+	Base     `disorder:",inline"`
+	Audit    *Audit `disorder:"audit,inline"`
+}
+
+type Base struct {
+	Version int32 `disorder:"version"`
+}
+
+type Audit struct {
+	Creator string `disorder:"creator"`
+}
+
+type RecursiveInline struct {
+	Next *RecursiveInline `disorder:",inline"`
+}
+
+type InvalidInline struct {
+	Value int32 `disorder:",inline"`
+}
+
+type ShadowedInline struct {
+	Version int32 `disorder:"version"`
+	Base    `disorder:",inline"`
+}
+
+type Entity struct {
+	ID        int64     `disorder:"id"`
+	CreatedAt time.Time `disorder:"created_at"`
+}
+
+type Profile struct {
+	Email string `disorder:"email"`
+}
+
+type User struct {
+	Entity
+	*Profile
+	ID   string `disorder:"id"`
+	Name string `disorder:"name"`
+}
+
+type Named struct {
+	Entity `disorder:"entity"`
+}
+
+type Left struct {
+	X    int32 `disorder:"x"`
+	Left int32 `disorder:"left"`
+}
+
+type Right struct {
+	X int32 `disorder:"x"`
+}
+
+type Conflict struct {
+	Left
+	Right
+}
+
+type DuplicatedKeys struct {
+	A int32 `disorder:"key"`
+	B int32 `disorder:"key"`
+}
+
+type DuplicatedNames struct {
+	A    int32 `disorder:"Name"`
+	Name int32
+}
+
+type PartialObject struct {
+	IntField int32 `disorder:"int_field"`
+	Unknown  disorder.Unknown
+}
+
+type Envelope struct {
+	Kind string              `disorder:"kind"`
+	Body disorder.RawMessage `disorder:"body"`
+}
+
+type ObjectEnvelope struct {
+	Kind string `disorder:"kind"`
+	Body Object `disorder:"body"`
+}
+
+// Keys records the keys of an object in encoded order.
+type Keys []string
+
+func (k *Keys) UnmarshalDisorder(d *disorder.Decoder) error {
+	return d.ReadObject(func(key string) error {
+		*k = append(*k, key)
+		return d.Skip()
+	})
+}
+
+// Version is a map key encoded as text, like "1.2".
+type Version struct {
+	Major int
+	Minor int
+}
+
+func (v Version) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%d", v.Major, v.Minor)), nil
+}
+
+func (v *Version) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d.%d", &v.Major, &v.Minor)
+	return err
+}
+
+// Revision is a map key encoded as text with pointer receivers, like "r3".
+type Revision struct {
+	Number int
+}
+
+func (r *Revision) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("r%d", r.Number)), nil
+}
+
+func (r *Revision) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "r%d", &r.Number)
+	return err
+}
+
+type MapKeys struct {
+	Users    map[int64]*Profile `disorder:"users"`
+	Colors   map[Color]int32    `disorder:"colors"`
+	Versions map[Version]string `disorder:"versions"`
+	Counts   map[uint8]bool     `disorder:"counts"`
+}
+
+type Pointers struct {
+	Int      *int32             `disorder:"int"`
+	String   *string            `disorder:"string"`
+	Nested   **Number           `disorder:"nested"`
+	Slice    *[]int32           `disorder:"slice"`
+	Map      *map[string]*int64 `disorder:"map"`
+	Color    **Color            `disorder:"color"`
+	Time     **time.Time        `disorder:"time"`
+	Nil      **Number           `disorder:"nil"`
+	Elements []**int32          `disorder:"elements"`
+}
+
+type Arrays struct {
+	UUID    [16]byte    `disorder:"uuid"`
+	Vector  [3]float64  `disorder:"vector"`
+	Points  [2]*Point   `disorder:"points"`
+	Matrix  [2][2]int32 `disorder:"matrix"`
+	Pointer *[4]byte    `disorder:"pointer"`
+	Empty   [0]int32    `disorder:"empty,omitempty"`
+}
+
+// Money has no exported fields, it is encoded by registered codecs.
+type Money struct {
+	cents int64
+}
+
+type Account struct {
+	Balance Money            `disorder:"balance"`
+	Limit   *Money           `disorder:"limit"`
+	History []Money          `disorder:"history"`
+	Address net.IP           `disorder:"address"`
+	Budgets map[string]Money `disorder:"budgets"`
+}
+
+// Blob is written as bytes with its length first.
+type Blob []byte
+
+func (b Blob) MarshalBinary() ([]byte, error) {
+	return append([]byte{byte(len(b))}, b...), nil
+}
+
+func (b *Blob) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || int(data[0]) != len(data)-1 {
+		return fmt.Errorf("invalid blob")
+	}
+	*b = append(Blob{}, data[1:]...)
+	return nil
+}
+
+// Both implements the text and the binary interfaces.
+type Both struct {
+	Value string
+}
+
+func (b Both) MarshalText() ([]byte, error) {
+	return []byte("text:" + b.Value), nil
+}
+
+func (b *Both) UnmarshalText(text []byte) error {
+	b.Value = strings.TrimPrefix(string(text), "text:")
+	return nil
+}
+
+func (b Both) MarshalBinary() ([]byte, error) {
+	return []byte("binary:" + b.Value), nil
+}
+
+func (b *Both) UnmarshalBinary(data []byte) error {
+	b.Value = strings.TrimPrefix(string(data), "binary:")
+	return nil
+}
+
+type Markers struct {
+	Big      *big.Int  `disorder:"big"`
+	Version  Version   `disorder:"version"`
+	Versions []Version `disorder:"versions"`
+	Blob     Blob      `disorder:"blob"`
+	Both     Both      `disorder:"both"`
+	Color    Color     `disorder:"color"`
+}
+
+// shortWriter writes at most n bytes in total and reports the shorter writes without error.
+type shortWriter struct {
+	bytes.Buffer
+	n int
+}
+
+func (w *shortWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		p = p[:w.n]
+	}
+	w.n -= len(p)
+	return w.Buffer.Write(p)
+}
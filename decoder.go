@@ -2,22 +2,83 @@ package disorder
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"reflect"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// captureChunkSize bounds the buffer used to capture skipped bytes.
+const captureChunkSize = 4096
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// DecodeMode selects how decoded values combine with the content of the value decoded into.
+type DecodeMode byte
+
+const (
+	// DecodeMerge keeps the struct fields and map entries missing from the data and replaces slices.
+	DecodeMerge DecodeMode = iota
+	// DecodeReplace zeroes structs and maps before decoding into them, so only the data remains.
+	DecodeReplace
+	// DecodeAppend is DecodeMerge appending array elements to existing slices.
+	DecodeAppend
+)
+
+// DecoderOptions limits the resources a decoder may use, zero values mean unlimited.
+type DecoderOptions struct {
+	// MaxBytesLength limits the length of a single bytes or string value.
+	MaxBytesLength int
+	// MaxDepth limits the nesting of arrays and objects.
+	MaxDepth int
+	// MaxElements limits the element count of a single array, map or object.
+	MaxElements int
+	// MaxTotalBytes limits the bytes consumed from the reader over the decoder life time.
+	MaxTotalBytes int64
+	// LenientNumbers allows reading any integer tag into any integer type and any
+	// floating point tag into any floating point type, as long as the value fits.
+	LenientNumbers bool
+	// DisallowUnknownFields fails at the first object field missing from the struct value
+	// instead of collecting a warning and skipping it.
+	DisallowUnknownFields bool
+	// Mode selects how decoded structs, maps and slices combine with existing values.
+	Mode DecodeMode
+}
+
 type Decoder struct {
 	reader   io.Reader
+	options  DecoderOptions
 	warnings []error
+	keys     []string
+	// captured collects the bytes read while capturing is set.
+	captured  []byte
+	capturing bool
+	current   tag
+	compact   bool
+	offset    int64
+	// start is the offset of the current value tag, which is followed by the key for object fields.
+	start int64
+	depth int
+	// scratch holds the fixed size values being read, so reading them does not allocate.
+	scratch  [8]byte
+	decoders map[reflect.Type]DecodeFunc
 }
 
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{
+func NewDecoder(r io.Reader, options ...DecoderOptions) *Decoder {
+	d := &Decoder{
 		reader: r,
 	}
+	if len(options) > 0 {
+		d.options = options[0]
+	}
+	return d
 }
 
 func (d *Decoder) Decode(value interface{}) (err error) {
@@ -26,193 +87,929 @@ func (d *Decoder) Decode(value interface{}) (err error) {
 			err = fmt.Errorf("recover panic when decoding disorder data: %s", r)
 		}
 	}()
+	offset := d.offset
 	var t tag
 	t, err = d.readTag()
 	if err != nil {
+		var eof *UnexpectedEOFError
+		if errors.As(err, &eof) && eof.Offset == offset {
+			return io.EOF
+		}
 		return err
 	}
-	return d.read(t, reflect.ValueOf(value))
+	d.current = t
+	d.start = offset
+	err = d.read(t, reflect.ValueOf(value))
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		typ := reflect.TypeOf(value)
+		for typ.Kind() == reflect.Ptr {
+			typ = typ.Elem()
+		}
+		if typ.Kind() == reflect.Struct {
+			decodeErr.Path = typ.Name() + decodeErr.Path
+		}
+		decodeErr.Path = strings.TrimPrefix(decodeErr.Path, ".")
+	}
+	return err
+}
+
+// Mode returns the decode mode for unmarshalers handling existing values themselves.
+func (d *Decoder) Mode() DecodeMode {
+	return d.options.Mode
+}
+
+// Offset returns the number of bytes consumed from the reader.
+func (d *Decoder) Offset() int64 {
+	return d.offset
 }
 
+// Warnings returns the problems the decoder skipped over, such as *UnknownFieldError.
 func (d *Decoder) Warnings() []error {
 	return d.warnings
 }
 
+// ReadValue reads the current value into any supported type, using its Unmarshaler if implemented.
+func (d *Decoder) ReadValue(value interface{}) error {
+	return d.read(d.current, reflect.ValueOf(value))
+}
+
+// IsNull reports whether the current value is null, a null has no data to read.
+func (d *Decoder) IsNull() bool {
+	return d.current == tagNull
+}
+
+func (d *Decoder) ReadBool(value *bool) error {
+	if d.current != tagBool {
+		return d.mismatch("bool")
+	}
+	b, err := d.readBool()
+	if err != nil {
+		return err
+	}
+	*value = b
+	return nil
+}
+
+func (d *Decoder) ReadInt(value *int32) error {
+	i, err := d.readSignedNumber("int32", tagInt, math.MinInt32, math.MaxInt32)
+	if err != nil {
+		return err
+	}
+	*value = int32(i)
+	return nil
+}
+
+func (d *Decoder) ReadLong(value *int64) error {
+	i, err := d.readSignedNumber("int64", tagLong, math.MinInt64, math.MaxInt64)
+	if err != nil {
+		return err
+	}
+	*value = i
+	return nil
+}
+
+func (d *Decoder) ReadShort(value *int16) error {
+	i, err := d.readSignedNumber("int16", tagShort, math.MinInt16, math.MaxInt16)
+	if err != nil {
+		return err
+	}
+	*value = int16(i)
+	return nil
+}
+
+func (d *Decoder) ReadUshort(value *uint16) error {
+	u, err := d.readUnsignedNumber("uint16", tagUshort, math.MaxUint16)
+	if err != nil {
+		return err
+	}
+	*value = uint16(u)
+	return nil
+}
+
+func (d *Decoder) ReadUint(value *uint32) error {
+	u, err := d.readUnsignedNumber("uint32", tagUint, math.MaxUint32)
+	if err != nil {
+		return err
+	}
+	*value = uint32(u)
+	return nil
+}
+
+func (d *Decoder) ReadUlong(value *uint64) error {
+	u, err := d.readUnsignedNumber("uint64", tagUlong, math.MaxUint64)
+	if err != nil {
+		return err
+	}
+	*value = u
+	return nil
+}
+
+func (d *Decoder) ReadFloat(value *float32) error {
+	f, err := d.readFloatNumber("float32", tagFloat)
+	if err != nil {
+		return err
+	}
+	if abs := math.Abs(f); abs > math.MaxFloat32 && abs <= math.MaxFloat64 {
+		return d.decodeError("float32", fmt.Errorf("value %g overflows float32", f))
+	}
+	*value = float32(f)
+	return nil
+}
+
+func (d *Decoder) ReadDouble(value *float64) error {
+	f, err := d.readFloatNumber("float64", tagDouble)
+	if err != nil {
+		return err
+	}
+	*value = f
+	return nil
+}
+
+func (d *Decoder) ReadBytes(value *[]byte) error {
+	if d.current != tagBytes {
+		return d.mismatch("[]byte")
+	}
+	bytes, err := d.readBytes()
+	if err != nil {
+		return err
+	}
+	*value = bytes
+	return nil
+}
+
+func (d *Decoder) ReadString(value *string) error {
+	if d.current != tagString {
+		return d.mismatch("string")
+	}
+	str, err := d.readString()
+	if err != nil {
+		return err
+	}
+	*value = str
+	return nil
+}
+
+// ReadTime reads a timestamp or a timestamp_ns.
+func (d *Decoder) ReadTime(value *time.Time) error {
+	if d.current != tagTimestamp && d.current != tagTimestampNs {
+		return d.mismatch("time.Time")
+	}
+	t, err := d.readTime(d.current)
+	if err != nil {
+		return err
+	}
+	*value = *t
+	return nil
+}
+
+func (d *Decoder) ReadDuration(value *time.Duration) error {
+	if d.current != tagDuration {
+		return d.mismatch("time.Duration")
+	}
+	duration, err := d.readDuration()
+	if err != nil {
+		return err
+	}
+	*value = duration
+	return nil
+}
+
+func (d *Decoder) ReadEnum(value Enum) error {
+	enum, err := d.readEnum(d.current, reflect.TypeOf(value).String())
+	if err != nil {
+		return err
+	}
+	return value.SetValue(enum)
+}
+
+// readEnum reads an enum name, or a string for enums stored by value which were written as strings.
+func (d *Decoder) readEnum(t tag, typ string) (string, error) {
+	switch t {
+	case tagEnum:
+		return d.readName()
+	case tagString:
+		return d.readString()
+	}
+	return "", d.decodeError(typ, fmt.Errorf("type mismatch: assign %s to %s", t, typ))
+}
+
+// ReadArray reads an array, fn is called once per element with the element as current value.
+func (d *Decoder) ReadArray(fn func() error) error {
+	if d.current != tagArrayStart {
+		return d.mismatch("array")
+	}
+	err := d.enter()
+	if err != nil {
+		return err
+	}
+	defer d.leave()
+	start := d.offset
+	t, err := d.readTag()
+	if err != nil {
+		return err
+	}
+	for count := 1; t != tagArrayEnd; count++ {
+		if d.options.MaxElements > 0 && count > d.options.MaxElements {
+			return d.limit("MaxElements", int64(d.options.MaxElements))
+		}
+		d.current = t
+		d.start = start
+		err = fn()
+		if err != nil {
+			return wrapError(err, fmt.Sprintf("[%d]", count-1), t, start)
+		}
+		start = d.offset
+		t, err = d.readTag()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadObject reads an object, fn is called once per field with the field as current value.
+func (d *Decoder) ReadObject(fn func(key string) error) error {
+	return d.readFields(fn, func(key string) string {
+		return "." + key
+	})
+}
+
+// ReadMap is ReadObject for objects decoded into maps, it only differs in the path of errors.
+func (d *Decoder) ReadMap(fn func(key string) error) error {
+	return d.readFields(fn, func(key string) string {
+		return fmt.Sprintf("[%q]", key)
+	})
+}
+
+// readFields reads the fields of an object, errors returned by fn are located with the segment of their key.
+func (d *Decoder) readFields(fn func(key string) error, segment func(key string) string) error {
+	if d.current != tagObjectStart {
+		return d.mismatch("object")
+	}
+	err := d.enter()
+	if err != nil {
+		return err
+	}
+	defer d.leave()
+	start := d.offset
+	t, err := d.readTag()
+	if err != nil {
+		return err
+	}
+	for count := 1; t != tagObjectEnd; count++ {
+		if d.options.MaxElements > 0 && count > d.options.MaxElements {
+			return d.limit("MaxElements", int64(d.options.MaxElements))
+		}
+		var name string
+		name, t, err = d.readKey(t)
+		if err != nil {
+			return err
+		}
+		d.current = t
+		d.start = start
+		err = fn(name)
+		if err != nil {
+			return wrapError(err, segment(name), t, start)
+		}
+		start = d.offset
+		t, err = d.readTag()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Skip discards the current value.
+func (d *Decoder) Skip() error {
+	return d.skip(d.current)
+}
+
+// SkipUnknown discards the current value of a field which is not found in the struct value.
+func (d *Decoder) SkipUnknown(key string, value interface{}) error {
+	typ := reflect.TypeOf(value)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	err := d.unknownField(typ, key)
+	if err != nil {
+		return err
+	}
+	return d.skip(d.current)
+}
+
+func (d *Decoder) enter() error {
+	d.depth++
+	if d.options.MaxDepth > 0 && d.depth > d.options.MaxDepth {
+		return d.limit("MaxDepth", int64(d.options.MaxDepth))
+	}
+	return nil
+}
+
+func (d *Decoder) leave() {
+	d.depth--
+}
+
+func (d *Decoder) limit(name string, max int64) error {
+	return &LimitError{
+		Limit:  name,
+		Max:    max,
+		Offset: d.offset,
+	}
+}
+
+// checkLength is called before allocating or consuming count bytes.
+func (d *Decoder) checkLength(count int64) error {
+	if d.options.MaxTotalBytes > 0 && d.offset+count > d.options.MaxTotalBytes {
+		return d.limit("MaxTotalBytes", d.options.MaxTotalBytes)
+	}
+	return nil
+}
+
+// unknownField reports the current field as missing from typ,
+// it is an error with DisallowUnknownFields and a warning otherwise.
+func (d *Decoder) unknownField(typ reflect.Type, key string) error {
+	err := &UnknownFieldError{
+		Type:   typ,
+		Key:    key,
+		Tag:    d.current.String(),
+		Offset: d.start,
+	}
+	if d.options.DisallowUnknownFields {
+		return err
+	}
+	d.warnings = append(d.warnings, err)
+	return nil
+}
+
+func (d *Decoder) mismatch(typ string) error {
+	return d.decodeError(typ, fmt.Errorf("type mismatch: assign %s to %s", d.current, typ))
+}
+
+// decodeError reports err for the current value expected to be of type typ,
+// the enclosing arrays and objects add their path segments while returning it.
+func (d *Decoder) decodeError(typ string, err error) error {
+	return &DecodeError{
+		Type:   typ,
+		Tag:    d.current.String(),
+		Offset: d.start,
+		Err:    err,
+	}
+}
+
+// wrapError prepends segment to the path of err, an error which is not a *DecodeError yet
+// is wrapped into one for the value with tag t starting at offset start.
+func wrapError(err error, segment string, t tag, start int64) error {
+	var decodeErr *DecodeError
+	if errors.As(err, &decodeErr) {
+		decodeErr.Path = segment + decodeErr.Path
+		return err
+	}
+	return &DecodeError{
+		Path:   segment,
+		Tag:    t.String(),
+		Offset: start,
+		Err:    err,
+	}
+}
+
 func (d *Decoder) read(t tag, value reflect.Value) error {
+	if value.Type() == rawMessageType && value.CanSet() {
+		raw, err := d.capture(t)
+		if err != nil {
+			return err
+		}
+		value.SetBytes(raw)
+		return nil
+	}
+	if t == tagNull {
+		d.readNull(value)
+		return nil
+	}
+	if value.Kind() == reflect.Ptr && value.IsNil() && value.CanSet() {
+		value.Set(reflect.New(value.Type().Elem()))
+	}
+	if decode := d.decodeFunc(value.Type()); decode != nil && value.CanAddr() {
+		d.current = t
+		return decode(d, value.Addr().Interface())
+	}
+	if u, ok := unmarshaler(value); ok {
+		d.current = t
+		return u.UnmarshalDisorder(d)
+	}
+	if (value.Type() == timeType || reflect.PtrTo(value.Type()).Implements(enumType)) && value.CanAddr() {
+		value = value.Addr()
+	}
 	switch i := value.Interface().(type) {
 	case *time.Time:
-		if t == tagTimestamp {
-			time, err := d.readTime()
+		if t == tagTimestamp || t == tagTimestampNs {
+			time, err := d.readTime(t)
 			if err != nil {
 				return err
 			}
 			value.Elem().Set(reflect.ValueOf(*time))
 			return nil
 		} else {
-			return fmt.Errorf("type mismatch: assign time to %s", value.Type())
+			return d.decodeError(value.Type().String(), fmt.Errorf("type mismatch: assign %s to %s", t, value.Type()))
 		}
 
 	case Enum:
-		if t == tagEnum {
-			enum, err := d.readName()
-			if err != nil {
-				return err
-			}
-			return i.SetValue(enum)
-		} else {
-			return fmt.Errorf("type mismatch: assign enum to %s", value.Type())
+		enum, err := d.readEnum(t, value.Type().String())
+		if err != nil {
+			return err
 		}
+		err = i.SetValue(enum)
+		if err != nil {
+			return d.decodeError(value.Type().String(), err)
+		}
+		return nil
+	}
+	if u, ok := textUnmarshaler(value); ok && t == tagString {
+		text, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		err = u.UnmarshalText(text)
+		if err != nil {
+			return d.decodeError(value.Type().String(), err)
+		}
+		return nil
+	}
+	if u, ok := binaryUnmarshaler(value); ok && t == tagBytes {
+		data, err := d.readBytes()
+		if err != nil {
+			return err
+		}
+		err = u.UnmarshalBinary(data)
+		if err != nil {
+			return d.decodeError(value.Type().String(), err)
+		}
+		return nil
+	}
+	if value.Kind() == reflect.Interface && value.NumMethod() == 0 {
+		if !value.IsNil() && value.Elem().Kind() == reflect.Ptr {
+			return d.read(t, value.Elem())
+		}
+		resolved, err := d.readAny(t)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(resolved))
+		return nil
 	}
 	if value.Kind() == reflect.Ptr {
 		return d.read(t, value.Elem())
 	}
-	var bytes []byte
 	var resolved interface{}
 	switch t {
 	case tagBool:
-		bytes = make([]byte, 1)
-		_, err := d.reader.Read(bytes)
+		b, err := d.readBool()
 		if err != nil {
 			return err
 		}
-		resolved = bytes[0] == 1
+		resolved = b
 		if value.Kind() == reflect.Bool {
-			value.SetBool(resolved.(bool))
+			value.SetBool(b)
 			return nil
 		}
 
 	case tagInt:
-		bytes = make([]byte, 4)
-		_, err := d.reader.Read(bytes)
+		i, err := d.readInt()
 		if err != nil {
 			return err
 		}
-		resolved = int32(binary.BigEndian.Uint32(bytes))
-		if value.Kind() == reflect.Int32 {
-			value.SetInt(int64(resolved.(int32)))
-			return nil
+		resolved = i
+		if ok, err := d.setInteger(t, value, int64(i)); ok {
+			return err
 		}
 
 	case tagLong:
-		bytes = make([]byte, 8)
-		_, err := d.reader.Read(bytes)
+		l, err := d.readLong()
 		if err != nil {
 			return err
 		}
-		resolved = int64(binary.BigEndian.Uint64(bytes))
-		if value.Kind() == reflect.Int64 {
-			value.SetInt(resolved.(int64))
-			return nil
+		resolved = l
+		if ok, err := d.setInteger(t, value, l); ok {
+			return err
+		}
+
+	case tagShort:
+		i, err := d.readShort()
+		if err != nil {
+			return err
+		}
+		resolved = i
+		if ok, err := d.setInteger(t, value, int64(i)); ok {
+			return err
+		}
+
+	case tagUshort:
+		u, err := d.readUshort()
+		if err != nil {
+			return err
+		}
+		resolved = u
+		if ok, err := d.setUnsigned(t, value, uint64(u)); ok {
+			return err
+		}
+
+	case tagUint:
+		u, err := d.readUint()
+		if err != nil {
+			return err
+		}
+		resolved = u
+		if ok, err := d.setUnsigned(t, value, uint64(u)); ok {
+			return err
+		}
+
+	case tagUlong:
+		u, err := d.readUlong()
+		if err != nil {
+			return err
+		}
+		resolved = u
+		if ok, err := d.setUnsigned(t, value, u); ok {
+			return err
 		}
 
 	case tagFloat:
-		bytes = make([]byte, 4)
-		_, err := d.reader.Read(bytes)
+		f, err := d.readFloat()
 		if err != nil {
 			return err
 		}
-		resolved = math.Float32frombits(binary.BigEndian.Uint32(bytes))
-		if value.Kind() == reflect.Float32 {
-			value.SetFloat(float64(resolved.(float32)))
-			return nil
+		resolved = f
+		if ok, err := d.setFloat(t, value, float64(f)); ok {
+			return err
 		}
 
 	case tagDouble:
-		bytes = make([]byte, 8)
-		_, err := d.reader.Read(bytes)
+		f, err := d.readDouble()
 		if err != nil {
 			return err
 		}
-		resolved = math.Float64frombits(binary.BigEndian.Uint64(bytes))
-		if value.Kind() == reflect.Float64 {
-			value.SetFloat(resolved.(float64))
+		resolved = f
+		if ok, err := d.setFloat(t, value, f); ok {
+			return err
+		}
+
+	case tagDuration:
+		duration, err := d.readDuration()
+		if err != nil {
+			return err
+		}
+		resolved = duration
+		if value.Type() == durationType {
+			value.SetInt(int64(duration))
 			return nil
 		}
 
 	case tagBytes:
-		bytes = make([]byte, 4)
-		_, err := d.reader.Read(bytes)
+		bytes, err := d.readBytes()
 		if err != nil {
 			return err
 		}
-		count := binary.BigEndian.Uint32(bytes)
-		resolved = []byte{}
-		if count > 0 {
-			bytes = make([]byte, count)
-			_, err := d.reader.Read(bytes)
-			if err != nil {
-				return err
-			}
-			resolved = bytes
-		}
+		resolved = bytes
 		if value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.Uint8 {
-			value.Set(reflect.ValueOf(resolved))
+			value.SetBytes(bytes)
+			return nil
+		}
+		if value.Kind() == reflect.Array && value.Type().Elem().Kind() == reflect.Uint8 {
+			if len(bytes) != value.Len() {
+				return d.decodeError(value.Type().String(), fmt.Errorf("length %d does not match %s", len(bytes), value.Type()))
+			}
+			reflect.Copy(value, reflect.ValueOf(bytes))
 			return nil
 		}
 
 	case tagString:
-		bytes = make([]byte, 4)
-		_, err := d.reader.Read(bytes)
+		str, err := d.readString()
 		if err != nil {
 			return err
 		}
-		count := binary.BigEndian.Uint32(bytes)
-		resolved = ""
-		if count > 0 {
-			bytes = make([]byte, count)
-			_, err := d.reader.Read(bytes)
-			if err != nil {
-				return err
-			}
-			resolved = string(bytes)
-		}
+		resolved = str
 		if value.Kind() == reflect.String {
-			value.SetString(resolved.(string))
+			value.SetString(str)
 			return nil
 		}
 
-	case tagArrayStart:
-		return d.readArray(value)
+	case tagArrayStart:
+		d.current = t
+		return d.readArray(value)
+
+	case tagObjectStart:
+		d.current = t
+		return d.readObject(value)
+
+	default:
+		return d.decodeError(value.Type().String(), fmt.Errorf("invalid tag: %d", t))
+	}
+	return d.decodeError(value.Type().String(), fmt.Errorf("type mismatch: assign %s to %s", reflect.ValueOf(resolved).Type(), value.Type()))
+}
+
+// readQuoted reads a string into a bool or number, for fields with the string option.
+func (d *Decoder) readQuoted(value reflect.Value) error {
+	s, err := d.readString()
+	if err != nil {
+		return err
+	}
+	value = reflect.Indirect(value)
+	switch value.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetUint(u)
+	default:
+		f, err := strconv.ParseFloat(s, value.Type().Bits())
+		if err != nil {
+			return err
+		}
+		value.SetFloat(f)
+	}
+	return nil
+}
+
+// readNull sets the nearest settable pointer, interface, map or slice to nil,
+// other values are left unchanged like encoding/json does.
+func (d *Decoder) readNull(value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if value.CanSet() {
+			value.Set(reflect.Zero(value.Type()))
+		} else if value.Kind() == reflect.Ptr && !value.IsNil() {
+			d.readNull(value.Elem())
+		}
+	}
+}
+
+// setInteger assigns a signed integer read with tag t to value if the kind of value accepts the tag,
+// values not fitting into the kind are reported as overflow.
+func (d *Decoder) setInteger(t tag, value reflect.Value, i int64) (bool, error) {
+	if !d.acceptInteger(t, value.Kind()) {
+		return false, nil
+	}
+	switch value.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64:
+		if value.OverflowInt(i) {
+			return true, d.decodeError(value.Type().String(), fmt.Errorf("value %d overflows %s", i, value.Type()))
+		}
+		value.SetInt(i)
+	default:
+		if i < 0 || value.OverflowUint(uint64(i)) {
+			return true, d.decodeError(value.Type().String(), fmt.Errorf("value %d overflows %s", i, value.Type()))
+		}
+		value.SetUint(uint64(i))
+	}
+	return true, nil
+}
+
+// setUnsigned is setInteger for unsigned integers.
+func (d *Decoder) setUnsigned(t tag, value reflect.Value, u uint64) (bool, error) {
+	if !d.acceptInteger(t, value.Kind()) {
+		return false, nil
+	}
+	switch value.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int, reflect.Int64:
+		if u > math.MaxInt64 || value.OverflowInt(int64(u)) {
+			return true, d.decodeError(value.Type().String(), fmt.Errorf("value %d overflows %s", u, value.Type()))
+		}
+		value.SetInt(int64(u))
+	default:
+		if value.OverflowUint(u) {
+			return true, d.decodeError(value.Type().String(), fmt.Errorf("value %d overflows %s", u, value.Type()))
+		}
+		value.SetUint(u)
+	}
+	return true, nil
+}
+
+func (d *Decoder) acceptInteger(t tag, kind reflect.Kind) bool {
+	expected := integerTag(kind)
+	return expected != tagUndefined && (expected == t || d.options.LenientNumbers)
+}
+
+func (d *Decoder) acceptFloat(t tag, kind reflect.Kind) bool {
+	expected := floatTag(kind)
+	return expected != tagUndefined && (expected == t || d.options.LenientNumbers)
+}
+
+func (d *Decoder) setFloat(t tag, value reflect.Value, f float64) (bool, error) {
+	if !d.acceptFloat(t, value.Kind()) {
+		return false, nil
+	}
+	if value.OverflowFloat(f) {
+		return true, d.decodeError(value.Type().String(), fmt.Errorf("value %g overflows %s", f, value.Type()))
+	}
+	value.SetFloat(f)
+	return true, nil
+}
+
+// readSignedNumber reads the current integer for the Read methods of signed types, integers with
+// other tags are converted when LenientNumbers is set and must fit into [min, max].
+func (d *Decoder) readSignedNumber(typ string, expected tag, min, max int64) (int64, error) {
+	if d.current != expected && !d.options.LenientNumbers {
+		return 0, d.mismatch(typ)
+	}
+	u, negative, err := d.readIntegerNumber(typ)
+	if err != nil {
+		return 0, err
+	}
+	i := int64(u)
+	if !negative && u > math.MaxInt64 || i < min || i > max {
+		if negative {
+			return 0, d.decodeError(typ, fmt.Errorf("value %d overflows %s", i, typ))
+		}
+		return 0, d.decodeError(typ, fmt.Errorf("value %d overflows %s", u, typ))
+	}
+	return i, nil
+}
+
+// readUnsignedNumber is readSignedNumber for unsigned types, which must fit into [0, max].
+func (d *Decoder) readUnsignedNumber(typ string, expected tag, max uint64) (uint64, error) {
+	if d.current != expected && !d.options.LenientNumbers {
+		return 0, d.mismatch(typ)
+	}
+	u, negative, err := d.readIntegerNumber(typ)
+	if err != nil {
+		return 0, err
+	}
+	if negative {
+		return 0, d.decodeError(typ, fmt.Errorf("value %d overflows %s", int64(u), typ))
+	}
+	if u > max {
+		return 0, d.decodeError(typ, fmt.Errorf("value %d overflows %s", u, typ))
+	}
+	return u, nil
+}
+
+// readIntegerNumber reads the current integer of any integer tag, negative values are returned
+// as their two's complement.
+func (d *Decoder) readIntegerNumber(typ string) (uint64, bool, error) {
+	switch d.current {
+	case tagShort:
+		i, err := d.readShort()
+		return uint64(i), i < 0, err
+	case tagInt:
+		i, err := d.readInt()
+		return uint64(i), i < 0, err
+	case tagLong:
+		i, err := d.readLong()
+		return uint64(i), i < 0, err
+	case tagUshort:
+		u, err := d.readUshort()
+		return uint64(u), false, err
+	case tagUint:
+		u, err := d.readUint()
+		return uint64(u), false, err
+	case tagUlong:
+		u, err := d.readUlong()
+		return u, false, err
+	}
+	return 0, false, d.mismatch(typ)
+}
+
+// readFloatNumber reads the current float or double, the other one is converted when LenientNumbers is set.
+func (d *Decoder) readFloatNumber(typ string, expected tag) (float64, error) {
+	if d.current != expected && !d.options.LenientNumbers {
+		return 0, d.mismatch(typ)
+	}
+	switch d.current {
+	case tagFloat:
+		f, err := d.readFloat()
+		return float64(f), err
+	case tagDouble:
+		return d.readDouble()
+	}
+	return 0, d.mismatch(typ)
+}
 
+// readAny builds a generic value tree for interface{} targets:
+// arrays become []interface{}, objects become map[string]interface{}
+// and enums become EnumValue.
+func (d *Decoder) readAny(t tag) (interface{}, error) {
+	var value interface{}
+	switch t {
+	case tagBool:
+		value = new(bool)
+	case tagInt:
+		value = new(int32)
+	case tagLong:
+		value = new(int64)
+	case tagShort:
+		value = new(int16)
+	case tagUshort:
+		value = new(uint16)
+	case tagUint:
+		value = new(uint32)
+	case tagUlong:
+		value = new(uint64)
+	case tagFloat:
+		value = new(float32)
+	case tagDouble:
+		value = new(float64)
+	case tagBytes:
+		value = new([]byte)
+	case tagString:
+		value = new(string)
+	case tagTimestamp, tagTimestampNs:
+		value = new(time.Time)
+	case tagDuration:
+		value = new(time.Duration)
+	case tagEnum:
+		value = new(EnumValue)
+	case tagArrayStart:
+		value = new([]interface{})
 	case tagObjectStart:
-		return d.readObject(value)
-
+		value = new(map[string]interface{})
 	default:
-		return fmt.Errorf("invalid tag: %d", t)
+		return nil, fmt.Errorf("invalid tag: %d", t)
+	}
+	v := reflect.ValueOf(value)
+	err := d.read(t, v)
+	if err != nil {
+		return nil, err
 	}
-	return fmt.Errorf("type mismatch: assign %s to %s", reflect.ValueOf(resolved).Type(), value.Type())
+	return v.Elem().Interface(), nil
 }
 
 func (d *Decoder) readArray(value reflect.Value) error {
-	if value.Kind() != reflect.Slice {
-		return fmt.Errorf("type mismatch, assign slice to type %s ", value.Type())
+	if value.Kind() != reflect.Slice && value.Kind() != reflect.Array {
+		return d.decodeError(value.Type().String(), fmt.Errorf("type mismatch: assign %s to %s", d.current, value.Type()))
 	}
-	elementType := value.Type().Elem()
-	t, err := d.readTag()
-	if err != nil {
-		return err
+	if value.Kind() == reflect.Array {
+		return d.readGoArray(value)
 	}
+	elementType := value.Type().Elem()
 	values := []reflect.Value{}
-	for t != tagArrayEnd {
+	err := d.ReadArray(func() error {
 		element := reflect.New(elementType).Elem()
 		if element.Kind() == reflect.Ptr && element.IsNil() {
 			elementValue := reflect.New(element.Type().Elem())
 			element.Set(elementValue)
 		}
-		err = d.read(t, element)
-		if err != nil {
-			return err
-		}
-		t, err = d.readTag()
+		err := d.read(d.current, element)
 		if err != nil {
 			return err
 		}
 		values = append(values, element)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 	count := len(values)
-	value.Set(reflect.MakeSlice(value.Type(), count, count))
+	slice := reflect.MakeSlice(value.Type(), count, count)
 	for i, v := range values {
-		value.Index(i).Set(v)
+		slice.Index(i).Set(v)
+	}
+	if d.options.Mode == DecodeAppend && !value.IsNil() {
+		slice = reflect.AppendSlice(value, slice)
+	}
+	value.Set(slice)
+	return nil
+}
+
+// readGoArray reads the elements into the Go array value, failing at the first element beyond its length.
+func (d *Decoder) readGoArray(value reflect.Value) error {
+	start := d.start
+	count := 0
+	err := d.ReadArray(func() error {
+		if count == value.Len() {
+			return fmt.Errorf("more than %d elements for %s", value.Len(), value.Type())
+		}
+		element := value.Index(count)
+		count++
+		element.Set(reflect.Zero(element.Type()))
+		if element.Kind() == reflect.Ptr {
+			element.Set(reflect.New(element.Type().Elem()))
+		}
+		return d.read(d.current, element)
+	})
+	if err != nil {
+		return err
+	}
+	if count != value.Len() {
+		return &DecodeError{
+			Type:   value.Type().String(),
+			Tag:    tagArrayStart.String(),
+			Offset: start,
+			Err:    fmt.Errorf("length %d does not match %s", count, value.Type()),
+		}
 	}
 	return nil
 }
@@ -220,101 +1017,333 @@ func (d *Decoder) readArray(value reflect.Value) error {
 func (d *Decoder) readObject(value reflect.Value) error {
 	switch value.Kind() {
 	case reflect.Struct:
+		start := d.start
 		info, err := getStructInfo(value.Type())
 		if err != nil {
 			return err
 		}
-		t, err := d.readTag()
-		if err != nil {
-			return err
+		var seen map[*fieldInfo]bool
+		if len(info.required) > 0 {
+			seen = make(map[*fieldInfo]bool, len(info.required))
 		}
-		for t != tagObjectEnd {
-			name, err := d.readName()
-			if err != nil {
-				return err
+		if d.options.Mode == DecodeReplace {
+			value.Set(reflect.Zero(value.Type()))
+		}
+		var unknown *Unknown
+		if info.unknown != nil {
+			unknown = value.FieldByIndex(info.unknown).Addr().Interface().(*Unknown)
+			*unknown = nil
+		}
+		err = d.ReadObject(func(name string) error {
+			fieldInfo, exists := info.fieldsMap[name]
+			if !exists && unknown != nil {
+				return d.readUnknown(value.Type(), name, unknown)
 			}
-			if fieldInfo, exists := info.fieldsMap[name]; exists {
-				field := value.Field(fieldInfo.index)
-				if field.Kind() == reflect.Ptr && field.IsNil() {
-					fieldValue := reflect.New(field.Type().Elem())
-					field.Set(fieldValue)
-				}
-				err = d.read(t, field)
+			if !exists {
+				err := d.unknownField(value.Type(), name)
 				if err != nil {
-					return fmt.Errorf("assign \"%s\" to field \"%s\" in struct \"%s\" failed: %s", field.Type(), name, value.Type(), err.Error())
+					return err
 				}
-			} else {
-				d.warnings = append(d.warnings, fmt.Errorf("field %s not found in struct %s", name, value.Type()))
-				err = d.skip(t)
+				return d.skip(d.current)
+			}
+			if fieldInfo.required {
+				seen[fieldInfo] = true
+			}
+			field := fieldByIndex(value, fieldInfo.index, true)
+			if field.Kind() == reflect.Ptr && field.IsNil() {
+				fieldValue := reflect.New(field.Type().Elem())
+				field.Set(fieldValue)
+			}
+			var err error
+			if fieldInfo.quoted && d.current == tagString {
+				err = d.readQuoted(field)
 				if err != nil {
-					return err
+					err = d.decodeError(field.Type().String(), err)
 				}
+			} else {
+				err = d.read(d.current, field)
 			}
-			t, err = d.readTag()
-			if err != nil {
-				return err
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		for _, field := range info.required {
+			if !seen[field] {
+				return &DecodeError{
+					Type:   value.Type().String(),
+					Tag:    tagObjectStart.String(),
+					Offset: start,
+					Err:    fmt.Errorf("required field \"%s\" missing in struct \"%s\"", field.key, value.Type()),
+				}
 			}
 		}
 		return nil
 
 	case reflect.Map:
-		if value.Type().Key().Kind() != reflect.String {
-			return fmt.Errorf("key type of map must be string")
+		if !validKey(value.Type().Key()) {
+			return d.decodeError(value.Type().String(), fmt.Errorf("unsupported map key type %s", value.Type().Key()))
 		}
 
 	default:
-		return fmt.Errorf("type mismatch, assign map to type %s ", value.Type())
+		return d.decodeError(value.Type().String(), fmt.Errorf("type mismatch: assign %s to %s", d.current, value.Type()))
 	}
 
 	valueType := value.Type()
 	keyType := valueType.Key()
 	elementType := valueType.Elem()
-	if value.IsNil() {
+	if value.IsNil() || d.options.Mode == DecodeReplace {
 		value.Set(reflect.MakeMap(valueType))
 	}
-	t, err := d.readTag()
-	if err != nil {
-		return err
-	}
-	for t != tagObjectEnd {
-		name, err := d.readName()
+	return d.ReadMap(func(name string) error {
+		key := reflect.New(keyType).Elem()
+		err := unmarshalKey(name, key)
 		if err != nil {
-			return err
+			return d.decodeError(keyType.String(), err)
 		}
-		key := reflect.New(keyType).Elem()
-		key.SetString(name)
 		element := reflect.New(elementType).Elem()
 		if element.Kind() == reflect.Ptr && element.IsNil() {
 			elementValue := reflect.New(element.Type().Elem())
 			element.Set(elementValue)
 		}
-		err = d.read(t, element)
+		err = d.read(d.current, element)
 		if err != nil {
 			return err
 		}
 		value.SetMapIndex(key, element)
-		t, err = d.readTag()
+		return nil
+	})
+}
+
+// readTime reads a timestamp or a timestamp_ns, the zero time instant is returned as time.Time{}.
+func (d *Decoder) readTime(t tag) (*time.Time, error) {
+	var result *time.Time
+	var err error
+	if t == tagTimestampNs {
+		result, err = d.readTimeNs()
+	} else {
+		result, err = d.readTimeMillis()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result.IsZero() {
+		result = &time.Time{}
+	}
+	return result, nil
+}
+
+func (d *Decoder) readTimeMillis() (*time.Time, error) {
+	timestamp, err := d.readSigned(8)
+	if err != nil {
+		return nil, err
+	}
+	result := time.UnixMilli(timestamp)
+	return &result, nil
+}
+
+// readTimeNs reads a timestamp_ns, times written with a UTC offset matching the local
+// offset at that instant are returned in local time, like time.Time.UnmarshalBinary does.
+func (d *Decoder) readTimeNs() (*time.Time, error) {
+	seconds, err := d.readSigned(8)
+	if err != nil {
+		return nil, err
+	}
+	nanos, err := d.readUnsigned(4)
+	if err != nil {
+		return nil, err
+	}
+	if nanos>>1 >= uint64(time.Second) {
+		return nil, fmt.Errorf("invalid nanoseconds %d at offset %d", nanos>>1, d.offset)
+	}
+	result := time.Unix(seconds, int64(nanos>>1)).UTC()
+	if nanos&1 == 1 {
+		offset, err := d.readSigned(4)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if _, local := result.In(time.Local).Zone(); int64(local) == offset {
+			result = result.In(time.Local)
+		} else {
+			result = result.In(time.FixedZone("", int(offset)))
 		}
 	}
-	return nil
+	return &result, nil
+}
+
+func (d *Decoder) readDuration() (time.Duration, error) {
+	duration, err := d.readSigned(8)
+	return time.Duration(duration), err
+}
+
+func (d *Decoder) readBool() (bool, error) {
+	bytes := d.scratch[:1]
+	err := d.readFull(bytes)
+	if err != nil {
+		return false, err
+	}
+	return bytes[0] == 1, nil
+}
+
+func (d *Decoder) readInt() (int32, error) {
+	i, err := d.readSigned(4)
+	return int32(i), err
+}
+
+func (d *Decoder) readLong() (int64, error) {
+	return d.readSigned(8)
+}
+
+func (d *Decoder) readShort() (int16, error) {
+	i, err := d.readSigned(2)
+	return int16(i), err
+}
+
+func (d *Decoder) readUshort() (uint16, error) {
+	u, err := d.readUnsigned(2)
+	return uint16(u), err
+}
+
+func (d *Decoder) readUint() (uint32, error) {
+	u, err := d.readUnsigned(4)
+	return uint32(u), err
+}
+
+func (d *Decoder) readUlong() (uint64, error) {
+	return d.readUnsigned(8)
+}
+
+// readSigned reads a size bytes integer, or a zigzag varint if the current tag is compact.
+func (d *Decoder) readSigned(size int) (int64, error) {
+	if d.compact {
+		u, err := d.readVarint()
+		if err != nil {
+			return 0, err
+		}
+		i := unzigzag(u)
+		if size < 8 && (i < -1<<(size*8-1) || i >= 1<<(size*8-1)) {
+			return 0, fmt.Errorf("varint %d overflows %d bytes integer", i, size)
+		}
+		return i, nil
+	}
+	u, err := d.readUnsigned(size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 2:
+		return int64(int16(u)), nil
+	case 4:
+		return int64(int32(u)), nil
+	default:
+		return int64(u), nil
+	}
+}
+
+// readUnsigned reads a size bytes unsigned integer, or a varint if the current tag is compact.
+func (d *Decoder) readUnsigned(size int) (uint64, error) {
+	if d.compact {
+		u, err := d.readVarint()
+		if err != nil {
+			return 0, err
+		}
+		if size < 8 && u >= 1<<(size*8) {
+			return 0, fmt.Errorf("varint %d overflows %d bytes unsigned integer", u, size)
+		}
+		return u, nil
+	}
+	bytes := d.scratch[:size]
+	err := d.readFull(bytes)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 2:
+		return uint64(binary.BigEndian.Uint16(bytes)), nil
+	case 4:
+		return uint64(binary.BigEndian.Uint32(bytes)), nil
+	default:
+		return binary.BigEndian.Uint64(bytes), nil
+	}
+}
+
+func (d *Decoder) readLength() (uint32, error) {
+	u, err := d.readUnsigned(4)
+	return uint32(u), err
 }
 
-func (d *Decoder) readTime() (*time.Time, error) {
-	bytes := make([]byte, 8)
-	_, err := d.reader.Read(bytes)
+// readVarint reads a LEB128 unsigned varint.
+func (d *Decoder) readVarint() (uint64, error) {
+	var value uint64
+	for i := 0; i < binary.MaxVarintLen64; i++ {
+		err := d.readFull(d.scratch[:1])
+		if err != nil {
+			return 0, err
+		}
+		b := d.scratch[0]
+		if b < 0x80 {
+			if i == binary.MaxVarintLen64-1 && b > 1 {
+				break
+			}
+			return value | uint64(b)<<(7*i), nil
+		}
+		value |= uint64(b&0x7f) << (7 * i)
+	}
+	return 0, fmt.Errorf("varint overflows 64 bits integer at offset %d", d.offset)
+}
+
+func (d *Decoder) readFloat() (float32, error) {
+	bytes := d.scratch[:4]
+	err := d.readFull(bytes)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float32frombits(binary.BigEndian.Uint32(bytes)), nil
+}
+
+func (d *Decoder) readDouble() (float64, error) {
+	bytes := d.scratch[:8]
+	err := d.readFull(bytes)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(bytes)), nil
+}
+
+func (d *Decoder) readBytes() ([]byte, error) {
+	count, err := d.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return []byte{}, nil
+	}
+	if d.options.MaxBytesLength > 0 && int64(count) > int64(d.options.MaxBytesLength) {
+		return nil, d.limit("MaxBytesLength", int64(d.options.MaxBytesLength))
+	}
+	err = d.checkLength(int64(count))
+	if err != nil {
+		return nil, err
+	}
+	bytes := make([]byte, count)
+	err = d.readFull(bytes)
 	if err != nil {
 		return nil, err
 	}
-	timestamp := int64(binary.BigEndian.Uint64(bytes))
-	t := time.UnixMilli(timestamp)
-	return &t, nil
+	return bytes, nil
+}
+
+func (d *Decoder) readString() (string, error) {
+	bytes, err := d.readBytes()
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
 }
 
 func (d *Decoder) readName() (string, error) {
-	bytes := make([]byte, 1)
-	_, err := d.reader.Read(bytes)
+	bytes := d.scratch[:1]
+	err := d.readFull(bytes)
 	if err != nil {
 		return "", err
 	}
@@ -323,38 +1352,119 @@ func (d *Decoder) readName() (string, error) {
 		return "", fmt.Errorf("empty name")
 	}
 	bytes = make([]byte, count)
-	_, err = d.reader.Read(bytes)
+	err = d.readFull(bytes)
 	if err != nil {
 		return "", err
 	}
 	return string(bytes), nil
 }
 
+// readKey reads the key of an object field starting with tag t, and returns it with the tag of the value.
+func (d *Decoder) readKey(t tag) (string, tag, error) {
+	// a captured key reference is replaced by the full key, the key table of the stream is gone when the bytes are used
+	mark := len(d.captured) - 1
+	if t != tagKeyRef {
+		name, err := d.readName()
+		if err != nil {
+			return "", t, err
+		}
+		if len(d.keys) < keyTableSize {
+			d.keys = append(d.keys, name)
+		}
+		return name, t, nil
+	}
+	index, err := d.readVarint()
+	if err != nil {
+		return "", t, err
+	}
+	if index >= uint64(len(d.keys)) {
+		return "", t, fmt.Errorf("invalid key index %d at offset %d", index, d.offset)
+	}
+	t, err = d.readTag()
+	if err != nil {
+		return "", t, err
+	}
+	if t == tagKeyRef || t == tagObjectEnd {
+		return "", t, fmt.Errorf("invalid tag: %d", t)
+	}
+	name := d.keys[index]
+	if d.capturing {
+		d.captured = append(d.captured[:mark], d.rawTag(t), byte(len(name)))
+		d.captured = append(d.captured, name...)
+	}
+	return name, t, nil
+}
+
+// rawTag returns the tag t as read, with its compact flag.
+func (d *Decoder) rawTag(t tag) byte {
+	if d.compact {
+		t |= tagCompact
+	}
+	return byte(t)
+}
+
+// capture skips a value starting with tag t, and returns its encoded bytes including the tag.
+func (d *Decoder) capture(t tag) ([]byte, error) {
+	d.captured = []byte{d.rawTag(t)}
+	d.capturing = true
+	err := d.skip(t)
+	captured := d.captured
+	d.captured = nil
+	d.capturing = false
+	return captured, err
+}
+
+// readTag reads the next tag, a compact flag is removed and kept until the next tag.
 func (d *Decoder) readTag() (tag, error) {
-	t := make([]byte, 1)
-	_, err := d.reader.Read(t)
-	return tag(t[0]), err
+	bytes := d.scratch[:1]
+	err := d.readFull(bytes)
+	if err != nil {
+		return tagUndefined, err
+	}
+	t := tag(bytes[0])
+	d.compact = t&tagCompact != 0
+	if d.compact {
+		t &^= tagCompact
+		if !compactable(t) {
+			return tagUndefined, fmt.Errorf("invalid tag: %d", bytes[0])
+		}
+	}
+	return t, nil
 }
 
 func (d *Decoder) skip(t tag) error {
-	var bytes []byte
+	if t == tagTimestampNs {
+		_, err := d.readTimeNs()
+		return err
+	}
+	if d.compact && t != tagString && t != tagBytes {
+		_, err := d.readVarint()
+		return err
+	}
 	switch t {
+	case tagNull:
+		return nil
+
 	case tagBool:
 		return d.skipBytes(1)
 
-	case tagInt, tagFloat:
+	case tagShort, tagUshort:
+		return d.skipBytes(2)
+
+	case tagInt, tagUint, tagFloat:
 		return d.skipBytes(4)
 
-	case tagLong, tagDouble, tagTimestamp:
+	case tagLong, tagUlong, tagDouble, tagTimestamp, tagDuration:
 		return d.skipBytes(8)
 
 	case tagString, tagBytes:
-		bytes = make([]byte, 4)
-		_, err := d.reader.Read(bytes)
+		count, err := d.readLength()
 		if err != nil {
 			return err
 		}
-		count := binary.BigEndian.Uint32(bytes)
+		if d.options.MaxBytesLength > 0 && int64(count) > int64(d.options.MaxBytesLength) {
+			return d.limit("MaxBytesLength", int64(d.options.MaxBytesLength))
+		}
 		return d.skipBytes(int(count))
 
 	case tagEnum:
@@ -372,14 +1482,54 @@ func (d *Decoder) skip(t tag) error {
 }
 
 func (d *Decoder) skipBytes(count int) error {
-	bytes := make([]byte, count)
-	_, err := d.reader.Read(bytes)
+	err := d.checkLength(int64(count))
+	if err != nil {
+		return err
+	}
+	if d.capturing {
+		// Read in bounded chunks, so a length taken from the wire allocates no more than the data present.
+		var chunk [captureChunkSize]byte
+		for count > 0 {
+			n := count
+			if n > len(chunk) {
+				n = len(chunk)
+			}
+			err = d.readFull(chunk[:n])
+			if err != nil {
+				return err
+			}
+			count -= n
+		}
+		return nil
+	}
+	n, err := io.CopyN(io.Discard, d.reader, int64(count))
+	d.offset += n
+	if err == io.EOF {
+		return &UnexpectedEOFError{Offset: d.offset}
+	}
+	return err
+}
+
+// readFull fills bytes completely, a stream ending inside a value is reported as UnexpectedEOFError.
+func (d *Decoder) readFull(bytes []byte) error {
+	err := d.checkLength(int64(len(bytes)))
+	if err != nil {
+		return err
+	}
+	n, err := io.ReadFull(d.reader, bytes)
+	d.offset += int64(n)
+	if d.capturing {
+		d.captured = append(d.captured, bytes[:n]...)
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &UnexpectedEOFError{Offset: d.offset}
+	}
 	return err
 }
 
 func (d *Decoder) skipName() error {
-	bytes := make([]byte, 1)
-	_, err := d.reader.Read(bytes)
+	bytes := d.scratch[:1]
+	err := d.readFull(bytes)
 	if err != nil {
 		return err
 	}
@@ -392,41 +1542,15 @@ func (d *Decoder) skipName() error {
 }
 
 func (d *Decoder) skipArray() error {
-	t, err := d.readTag()
-	if err != nil {
-		return err
-	}
-	for t != tagArrayEnd {
-		err = d.skip(t)
-		if err != nil {
-			return err
-		}
-		t, err = d.readTag()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	d.current = tagArrayStart
+	return d.ReadArray(func() error {
+		return d.skip(d.current)
+	})
 }
 
 func (d *Decoder) skipObject() error {
-	t, err := d.readTag()
-	if err != nil {
-		return err
-	}
-	for t != tagObjectEnd {
-		err = d.skipName()
-		if err != nil {
-			return err
-		}
-		err = d.skip(t)
-		if err != nil {
-			return err
-		}
-		t, err = d.readTag()
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	d.current = tagObjectStart
+	return d.ReadObject(func(string) error {
+		return d.skip(d.current)
+	})
 }
@@ -2,16 +2,50 @@ package disorder
 
 import (
 	"bytes"
+	"sync"
 )
 
-func Marshal(value interface{}) ([]byte, error) {
-	buffer := &bytes.Buffer{}
-	encoder := NewEncoder(buffer)
+// maxPooledBuffer prevents a huge value from pinning its buffer in the pool.
+const maxPooledBuffer = 64 * 1024
+
+var encoderPool = sync.Pool{
+	New: func() interface{} {
+		return &Encoder{
+			buffer: make([]byte, 0, bufferSize),
+		}
+	},
+}
+
+func Marshal(value interface{}, options ...EncoderOptions) ([]byte, error) {
+	encoder := encoderPool.Get().(*Encoder)
+	encoder.options = EncoderOptions{}
+	if len(options) > 0 {
+		encoder.options = options[0]
+	}
+	defer func() {
+		if cap(encoder.buffer) > maxPooledBuffer {
+			encoder.buffer = make([]byte, 0, bufferSize)
+		}
+		encoder.buffer = encoder.buffer[:0]
+		encoder.keys = nil
+		encoder.keyCount = 0
+		encoder.encoders = nil
+		encoder.containers = encoder.containers[:0]
+		encoderPool.Put(encoder)
+	}()
 	err := encoder.Encode(value)
-	if err == nil {
-		return buffer.Bytes(), nil
+	if err != nil {
+		return nil, err
 	}
-	return nil, err
+	data := make([]byte, len(encoder.buffer))
+	copy(data, encoder.buffer)
+	return data, nil
+}
+
+// Canonical marshals value with sorted map keys, equal values always give the same bytes
+// which makes the result suitable for hashing, signatures and golden files.
+func Canonical(value interface{}) ([]byte, error) {
+	return Marshal(value, EncoderOptions{Canonical: true})
 }
 
 func Unmarshal(data []byte, value interface{}) error {
@@ -1,9 +1,19 @@
 package disorder_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
 	"reflect"
+	"runtime"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"github.com/meerkat-io/disorder"
@@ -76,7 +86,7 @@ func TestPrimaryTypes(t *testing.T) {
 }
 
 func TestUnsupportedEncodeTypes(t *testing.T) {
-	_, err := disorder.Marshal(int(123))
+	_, err := disorder.Marshal(complex64(123))
 	assert.NotNil(t, err)
 
 	_, err = disorder.Marshal(nil)
@@ -273,3 +283,1573 @@ func TestZero(t *testing.T) {
 	assert.Equal(t, 0, len(object1.ZeroArray))
 	assert.Equal(t, 0, len(object1.ZeroMap))
 }
+
+func TestInterfaceTypes(t *testing.T) {
+	timestamp := time.UnixMilli(time.Now().UnixMilli())
+	object0 := Object{
+		BooleanField: true,
+		IntField:     123,
+		StringField:  "foo",
+		BytesFields:  []byte{7, 8, 9},
+		EnumField:    &ColorBlue,
+		TimeField:    &timestamp,
+		IntArray:     []int32{1, 2, 3},
+		ObjMap: map[string]*NumberWrapper{
+			"789": {Value: &Number{
+				Value: 789,
+			}},
+		},
+	}
+	data0, err := disorder.Marshal(&object0)
+	assert.Nil(t, err)
+
+	var object1 interface{}
+	err = disorder.Unmarshal(data0, &object1)
+	assert.Nil(t, err)
+	fields := object1.(map[string]interface{})
+	assert.Equal(t, true, fields["boolean_field"])
+	assert.Equal(t, int32(123), fields["int_field"])
+	assert.Equal(t, "foo", fields["string_field"])
+	assert.Equal(t, []byte{7, 8, 9}, fields["bytes_fields"])
+	assert.Equal(t, disorder.EnumValue("blue"), fields["enum_field"])
+	assert.Equal(t, timestamp, fields["time_field"])
+	assert.Equal(t, []interface{}{int32(1), int32(2), int32(3)}, fields["int_array"])
+	assert.Equal(t, map[string]interface{}{
+		"789": map[string]interface{}{
+			"value": map[string]interface{}{
+				"value": int32(789),
+			},
+		},
+	}, fields["obj_map"])
+
+	data1, err := disorder.Marshal(object1)
+	assert.Nil(t, err)
+	var object2 Object
+	err = disorder.Unmarshal(data1, &object2)
+	assert.Nil(t, err)
+	assert.Equal(t, object0, object2)
+
+	var wrapper struct {
+		Value interface{} `disorder:"value"`
+	}
+	data2, err := disorder.Marshal(NumberWrapper{Value: &Number{Value: 456}})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data2, &wrapper)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"value": int32(456)}, wrapper.Value)
+}
+
+func TestMarshaler(t *testing.T) {
+	points0 := []Point{{X: 1, Y: 2}, {X: 3, Y: 4}}
+	data, err := disorder.Marshal(points0)
+	assert.Nil(t, err)
+
+	var points1 []Point
+	err = disorder.Unmarshal(data, &points1)
+	assert.Nil(t, err)
+	assert.Equal(t, points0, points1)
+
+	var points2 interface{}
+	err = disorder.Unmarshal(data, &points2)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"x": int32(1), "y": int32(2)},
+		map[string]interface{}{"x": int32(3), "y": int32(4)},
+	}, points2)
+
+	var point Point
+	data, err = disorder.Marshal(map[string]int32{"x": 5, "z": 6})
+	assert.Nil(t, err)
+	decoder := disorder.NewDecoder(bytes.NewBuffer(data))
+	err = decoder.Decode(&point)
+	assert.Nil(t, err)
+	assert.Equal(t, Point{X: 5}, point)
+	assert.Equal(t, 1, len(decoder.Warnings()))
+
+	data, err = disorder.Marshal(int32(1))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &point)
+	assert.NotNil(t, err)
+
+	// Values which are not addressable are written by MarshalDisorder too
+	data, err = disorder.Marshal(&Point{X: 7, Y: 8})
+	assert.Nil(t, err)
+	value, err := disorder.Marshal(Point{X: 7, Y: 8})
+	assert.Nil(t, err)
+	assert.Equal(t, data, value)
+	data, err = disorder.Marshal(map[string]*Point{"p": {X: 7, Y: 8}})
+	assert.Nil(t, err)
+	value, err = disorder.Marshal(map[string]Point{"p": {X: 7, Y: 8}})
+	assert.Nil(t, err)
+	assert.Equal(t, data, value)
+}
+
+func TestShortReads(t *testing.T) {
+	timestamp := time.UnixMilli(time.Now().UnixMilli())
+	object0 := Object{
+		StringField: "foo",
+		BytesFields: []byte{7, 8, 9},
+		EnumField:   &ColorBlue,
+		TimeField:   &timestamp,
+		IntMap:      map[string]int32{"4": 4},
+	}
+	data, err := disorder.Marshal(&object0)
+	assert.Nil(t, err)
+
+	var object1 Object
+	decoder := disorder.NewDecoder(iotest.OneByteReader(bytes.NewBuffer(data)))
+	err = decoder.Decode(&object1)
+	assert.Nil(t, err)
+	assert.Equal(t, object0, object1)
+	assert.Equal(t, int64(len(data)), decoder.Offset())
+
+	err = decoder.Decode(&object1)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestTruncatedData(t *testing.T) {
+	data, err := disorder.Marshal(map[string][]string{"hello": {"world"}})
+	assert.Nil(t, err)
+
+	for i := 1; i < len(data); i++ {
+		var value map[string][]string
+		err = disorder.Unmarshal(data[:i], &value)
+		assert.True(t, errors.Is(err, disorder.ErrUnexpectedEOF))
+		var eof *disorder.UnexpectedEOFError
+		assert.True(t, errors.As(err, &eof))
+		assert.Equal(t, int64(i), eof.Offset)
+	}
+
+	var value map[string][]string
+	err = disorder.Unmarshal([]byte{}, &value)
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestDecoderLimits(t *testing.T) {
+	var bytes0 []byte
+	hostile := []byte{6, 0xff, 0xff, 0xff, 0xff}
+	decoder := disorder.NewDecoder(bytes.NewBuffer(hostile), disorder.DecoderOptions{MaxBytesLength: 1024})
+	err := decoder.Decode(&bytes0)
+	assert.True(t, errors.Is(err, disorder.ErrLimitExceeded))
+	var limit *disorder.LimitError
+	assert.True(t, errors.As(err, &limit))
+	assert.Equal(t, "MaxBytesLength", limit.Limit)
+
+	decoder = disorder.NewDecoder(bytes.NewBuffer(hostile), disorder.DecoderOptions{MaxTotalBytes: 1024})
+	err = decoder.Decode(&bytes0)
+	assert.True(t, errors.Is(err, disorder.ErrLimitExceeded))
+
+	nested := [][][]int32{{{1}}}
+	data, err := disorder.Marshal(nested)
+	assert.Nil(t, err)
+	var result [][][]int32
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{MaxDepth: 3})
+	err = decoder.Decode(&result)
+	assert.Nil(t, err)
+	assert.Equal(t, nested, result)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{MaxDepth: 2})
+	err = decoder.Decode(&result)
+	assert.True(t, errors.Is(err, disorder.ErrLimitExceeded))
+
+	data, err = disorder.Marshal(map[string]int32{"1": 1, "2": 2, "3": 3})
+	assert.Nil(t, err)
+	var set map[string]int32
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{MaxElements: 3})
+	err = decoder.Decode(&set)
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{MaxElements: 2})
+	err = decoder.Decode(&set)
+	assert.True(t, errors.Is(err, disorder.ErrLimitExceeded))
+
+	var skip SkipObject
+	data, err = disorder.Marshal(map[string][]int32{"skipped": {1, 2, 3}})
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{MaxElements: 2})
+	err = decoder.Decode(&skip)
+	assert.True(t, errors.Is(err, disorder.ErrLimitExceeded))
+
+	data, err = disorder.Marshal(map[string]string{"skipped": strings.Repeat("x", 4096)})
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{MaxBytesLength: 16})
+	err = decoder.Decode(&skip)
+	assert.True(t, errors.Is(err, disorder.ErrLimitExceeded))
+}
+
+func TestDecoderAllocations(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer)
+	for i := 0; i < 101; i++ {
+		assert.Nil(t, encoder.Encode(float64(i)))
+	}
+	assert.Nil(t, encoder.Flush())
+	decoder := disorder.NewDecoder(bytes.NewReader(buffer.Bytes()))
+	var f float64
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = decoder.Decode(&f)
+	})
+	assert.Equal(t, float64(100), f)
+	// Tags and fixed size values are read into the scratch of the decoder, what remains is from reflection
+	assert.LessOrEqual(t, allocs, float64(2))
+}
+
+func TestEncoderFlush(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer)
+	err := encoder.Encode("hello")
+	assert.Nil(t, err)
+	err = encoder.Encode(int32(123))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, buffer.Len())
+	err = encoder.Flush()
+	assert.Nil(t, err)
+
+	var s string
+	var i int32
+	decoder := disorder.NewDecoder(buffer)
+	err = decoder.Decode(&s)
+	assert.Nil(t, err)
+	assert.Equal(t, "hello", s)
+	err = decoder.Decode(&i)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(123), i)
+
+	large := make([]string, 1000)
+	for i := range large {
+		large[i] = "large buffer"
+	}
+	err = encoder.Encode(large)
+	assert.Nil(t, err)
+	assert.True(t, buffer.Len() > 0)
+	err = encoder.Flush()
+	assert.Nil(t, err)
+	var result []string
+	err = decoder.Decode(&result)
+	assert.Nil(t, err)
+	assert.Equal(t, large, result)
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	writer := &shortWriter{n: 100}
+	encoder := disorder.NewEncoder(writer)
+	assert.Nil(t, encoder.BeginArray(""))
+	var err error
+	for i := 0; i < 1000 && err == nil; i++ {
+		err = encoder.WriteValue("", "row")
+	}
+	assert.Equal(t, io.ErrShortWrite, err)
+	written := writer.Len()
+
+	// The stream misses bytes, so the encoder stays broken
+	assert.Equal(t, err, encoder.WriteValue("", "row"))
+	assert.Equal(t, err, encoder.EndArray())
+	assert.Equal(t, err, encoder.Flush())
+	assert.Equal(t, err, encoder.Encode(int32(1)))
+	assert.Equal(t, written, writer.Len())
+
+	encoder = disorder.NewEncoder(&shortWriter{n: 0})
+	assert.Nil(t, encoder.Encode(int32(1)))
+	assert.Equal(t, io.ErrShortWrite, encoder.Flush())
+	assert.Equal(t, io.ErrShortWrite, encoder.WriteInt("", 2))
+}
+
+func TestIntegerTypes(t *testing.T) {
+	integers0 := Integers{
+		Int:     -1 << 40,
+		Int8:    math.MinInt8,
+		Int16:   math.MaxInt16,
+		Uint:    1 << 40,
+		Uint8:   math.MaxUint8,
+		Uint16:  math.MaxUint16,
+		Uint32:  math.MaxUint32,
+		Uint64:  math.MaxUint64,
+		Uintptr: 0xff,
+	}
+	data, err := disorder.Marshal(&integers0)
+	assert.Nil(t, err)
+	var integers1 Integers
+	err = disorder.Unmarshal(data, &integers1)
+	assert.Nil(t, err)
+	assert.Equal(t, integers0, integers1)
+
+	var u64 uint64
+	data, err = disorder.Marshal(uint64(math.MaxUint64))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &u64)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u64)
+	var i64 interface{}
+	err = disorder.Unmarshal(data, &i64)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), i64)
+	decoder := disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&i64)
+	assert.Nil(t, err)
+	var l int64
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&l)
+	assert.NotNil(t, err)
+
+	var i8 int8
+	data, err = disorder.Marshal(int16(128))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &i8)
+	assert.NotNil(t, err)
+	data, err = disorder.Marshal(int16(-128))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &i8)
+	assert.Nil(t, err)
+	assert.Equal(t, int8(-128), i8)
+
+	var u16 uint16
+	data, err = disorder.Marshal(int32(-1))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &u16)
+	assert.NotNil(t, err)
+
+	data, err = disorder.Marshal(int32(123))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &l)
+	assert.NotNil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&l)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123), l)
+
+	var i32 int32
+	data, err = disorder.Marshal(int64(math.MaxInt64))
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&i32)
+	assert.NotNil(t, err)
+
+	var d float64
+	data, err = disorder.Marshal(float32(0.5))
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&d)
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, d)
+
+	// Hand written unmarshalers follow the same rules
+	var point Point
+	data, err = disorder.Marshal(map[string]interface{}{"x": int64(5), "y": uint16(6)})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &point)
+	assert.NotNil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&point)
+	assert.Nil(t, err)
+	assert.Equal(t, Point{X: 5, Y: 6}, point)
+	data, err = disorder.Marshal(map[string]interface{}{"x": int64(math.MaxInt64)})
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&point)
+	assert.NotNil(t, err)
+	data, err = disorder.Marshal(map[string]interface{}{"x": uint32(math.MaxUint32)})
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&point)
+	assert.EqualError(t, err, "decode Point.x failed at offset 1: value 4294967295 overflows int32")
+	data, err = disorder.Marshal(map[string]interface{}{"x": 0.5})
+	assert.Nil(t, err)
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{LenientNumbers: true})
+	err = decoder.Decode(&point)
+	assert.NotNil(t, err)
+}
+
+func TestCompactEncoding(t *testing.T) {
+	compact := disorder.EncoderOptions{Compact: true}
+	fixtures := []struct {
+		name  string
+		value interface{}
+	}{
+		{"Object", newObject()},
+		{"Number", Number{Value: 123}},
+		{"Integers", Integers{Int: 1, Int8: -1, Int16: 300, Uint: 2, Uint8: 3, Uint16: 4, Uint32: 5, Uint64: 6, Uintptr: 7}},
+		{"Point", &Point{X: -1, Y: 1}},
+	}
+	for _, fixture := range fixtures {
+		fixed, err := disorder.Marshal(fixture.value)
+		assert.Nil(t, err)
+		compacted, err := disorder.Marshal(fixture.value, compact)
+		assert.Nil(t, err)
+		assert.Less(t, len(compacted), len(fixed))
+		t.Logf("%-8s fixed %3d bytes, compact %3d bytes", fixture.name, len(fixed), len(compacted))
+
+		result := reflect.New(reflect.Indirect(reflect.ValueOf(fixture.value)).Type())
+		err = disorder.Unmarshal(compacted, result.Interface())
+		assert.Nil(t, err)
+		assert.Equal(t, reflect.Indirect(reflect.ValueOf(fixture.value)).Interface(), result.Elem().Interface())
+	}
+
+	integers0 := Integers{
+		Int:     math.MinInt64,
+		Int8:    math.MinInt8,
+		Int16:   math.MaxInt16,
+		Uint:    math.MaxUint64,
+		Uint8:   math.MaxUint8,
+		Uint16:  math.MaxUint16,
+		Uint32:  math.MaxUint32,
+		Uint64:  math.MaxUint64,
+		Uintptr: 0xff,
+	}
+	data, err := disorder.Marshal(&integers0, compact)
+	assert.Nil(t, err)
+	var integers1 Integers
+	err = disorder.Unmarshal(data, &integers1)
+	assert.Nil(t, err)
+	assert.Equal(t, integers0, integers1)
+
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer, compact)
+	assert.Nil(t, encoder.Encode(int32(-1)))
+	assert.Nil(t, encoder.Flush())
+	assert.Equal(t, []byte{0x82, 0x01}, buffer.Bytes())
+	encoder = disorder.NewEncoder(buffer)
+	assert.Nil(t, encoder.Encode(map[string]string{"hello": "world"}))
+	assert.Nil(t, encoder.Flush())
+	decoder := disorder.NewDecoder(buffer)
+	var i int32
+	assert.Nil(t, decoder.Decode(&i))
+	assert.Equal(t, int32(-1), i)
+	var m map[string]string
+	assert.Nil(t, decoder.Decode(&m))
+	assert.Equal(t, map[string]string{"hello": "world"}, m)
+
+	data, err = disorder.Marshal(int64(math.MaxInt64), compact)
+	assert.Nil(t, err)
+	data[0] = 0x82
+	err = disorder.Unmarshal(data, &i)
+	assert.NotNil(t, err)
+
+	err = disorder.Unmarshal([]byte{0x81, 1}, new(bool))
+	assert.NotNil(t, err)
+	err = disorder.Unmarshal([]byte{0x83, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f}, new(int64))
+	assert.NotNil(t, err)
+
+	var skip SkipObject
+	data, err = disorder.Marshal(map[string]interface{}{"skipped": []interface{}{int64(1), "two", time.Now()}}, compact)
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &skip)
+	assert.Nil(t, err)
+}
+
+func TestInternKeys(t *testing.T) {
+	intern := disorder.EncoderOptions{InternKeys: true}
+	objects0 := make([]Object, 100)
+	for i := range objects0 {
+		objects0[i] = newObject()
+	}
+	full, err := disorder.Marshal(objects0)
+	assert.Nil(t, err)
+	interned, err := disorder.Marshal(objects0, intern)
+	assert.Nil(t, err)
+	assert.Less(t, len(interned), len(full)*2/3)
+	t.Logf("100 objects: full keys %d bytes, interned keys %d bytes", len(full), len(interned))
+	var objects1 []Object
+	err = disorder.Unmarshal(interned, &objects1)
+	assert.Nil(t, err)
+	assert.Equal(t, objects0, objects1)
+
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer, intern)
+	assert.Nil(t, encoder.Encode(Number{Value: 1}))
+	assert.Nil(t, encoder.Encode(Number{Value: 2}))
+	assert.Nil(t, encoder.Flush())
+	decoder := disorder.NewDecoder(buffer)
+	var number Number
+	assert.Nil(t, decoder.Decode(&number))
+	assert.Equal(t, int32(1), number.Value)
+	assert.Nil(t, decoder.Decode(&number))
+	assert.Equal(t, int32(2), number.Value)
+
+	large := map[string]int32{}
+	for i := 0; i < 2000; i++ {
+		large[fmt.Sprintf("key%d", i)] = int32(i)
+	}
+	data, err := disorder.Marshal([]map[string]int32{large, large}, intern)
+	assert.Nil(t, err)
+	var result []map[string]int32
+	err = disorder.Unmarshal(data, &result)
+	assert.Nil(t, err)
+	assert.Equal(t, []map[string]int32{large, large}, result)
+
+	err = disorder.Unmarshal([]byte{23, 25, 0, 1, 1}, &map[string]bool{})
+	assert.NotNil(t, err)
+	var skip SkipObject
+	data, err = disorder.Marshal([]interface{}{map[string]int32{"skipped": 1}, map[string]int32{"skipped": 2}}, intern)
+	assert.Nil(t, err)
+	var skips []SkipObject
+	err = disorder.Unmarshal(data, &skips)
+	assert.Nil(t, err)
+	assert.Equal(t, []SkipObject{skip, skip}, skips)
+}
+
+func TestTimeTypes(t *testing.T) {
+	nanos := disorder.EncoderOptions{Time: disorder.TimeNanos}
+	times := []time.Time{
+		time.Unix(1700000000, 123456789).UTC(),
+		time.Unix(1700000000, 123456789).Local(),
+		time.Unix(-1700000000, 1).In(time.FixedZone("", -(3*3600 + 30*60))),
+	}
+	for _, options := range []disorder.EncoderOptions{nanos, {Time: disorder.TimeNanos, Compact: true}} {
+		for _, time0 := range times {
+			data, err := disorder.Marshal(time0, options)
+			assert.Nil(t, err)
+			var time1 time.Time
+			err = disorder.Unmarshal(data, &time1)
+			assert.Nil(t, err)
+			assert.True(t, time0.Equal(time1))
+			assert.Equal(t, time0.Format(time.RFC3339Nano), time1.Format(time.RFC3339Nano))
+		}
+	}
+	assert.Equal(t, time.UTC, times[0].Location())
+	data, err := disorder.Marshal(&times[1], nanos)
+	assert.Nil(t, err)
+	var local time.Time
+	err = disorder.Unmarshal(data, &local)
+	assert.Nil(t, err)
+	assert.Equal(t, times[1], local)
+
+	data, err = disorder.Marshal(&times[0])
+	assert.Nil(t, err)
+	var millis interface{}
+	err = disorder.Unmarshal(data, &millis)
+	assert.Nil(t, err)
+	assert.Equal(t, times[0].Truncate(time.Millisecond).UnixNano(), millis.(time.Time).UnixNano())
+
+	var duration time.Duration
+	data, err = disorder.Marshal(-1500 * time.Microsecond)
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &duration)
+	assert.Nil(t, err)
+	assert.Equal(t, -1500*time.Microsecond, duration)
+	var any interface{}
+	err = disorder.Unmarshal(data, &any)
+	assert.Nil(t, err)
+	assert.Equal(t, -1500*time.Microsecond, any)
+	var l int64
+	err = disorder.Unmarshal(data, &l)
+	assert.NotNil(t, err)
+	data, err = disorder.Marshal(int64(time.Second))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &duration)
+	assert.Nil(t, err)
+	assert.Equal(t, time.Second, duration)
+
+	var skip SkipObject
+	data, err = disorder.Marshal(map[string]interface{}{"time": times[2], "duration": time.Hour, "empty_string": "foo"}, nanos)
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &skip)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", skip.EmptyString)
+}
+
+func TestTimeValues(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	times0 := Times{
+		Time:      now,
+		TimeArray: []time.Time{now, {}, now.Add(time.Hour)},
+		TimeMap:   map[string]time.Time{"now": now, "zero": {}},
+	}
+	for _, options := range []disorder.EncoderOptions{{}, {Time: disorder.TimeNanos}, {Compact: true}} {
+		data, err := disorder.Marshal(times0, options)
+		assert.Nil(t, err)
+		var times1 Times
+		err = disorder.Unmarshal(data, &times1)
+		assert.Nil(t, err)
+		assert.Equal(t, times0, times1)
+		assert.Equal(t, time.Time{}, times1.Zero)
+	}
+
+	var zero time.Time
+	data, err := disorder.Marshal(time.Time{})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &zero)
+	assert.Nil(t, err)
+	assert.True(t, zero.IsZero())
+	assert.Equal(t, time.Time{}, zero)
+
+	var times []time.Time
+	data, err = disorder.Marshal([]*time.Time{&now})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &times)
+	assert.Nil(t, err)
+	assert.Equal(t, []time.Time{now}, times)
+}
+
+func TestEncodeTimeValues(t *testing.T) {
+	now := time.UnixMilli(time.Now().UnixMilli())
+	zero := time.Time{}
+	later := now.Add(time.Hour)
+	for _, options := range []disorder.EncoderOptions{{Canonical: true}, {Time: disorder.TimeNanos, Canonical: true}, {Compact: true, Canonical: true}} {
+		// time.Time values are written exactly like pointers to them, never as objects
+		values, err := disorder.Marshal(map[string]interface{}{
+			"time":       now,
+			"time_array": []time.Time{now, zero, later},
+			"time_map":   map[string]time.Time{"now": now, "zero": zero},
+		}, options)
+		assert.Nil(t, err)
+		pointers, err := disorder.Marshal(map[string]interface{}{
+			"time":       &now,
+			"time_array": []*time.Time{&now, &zero, &later},
+			"time_map":   map[string]*time.Time{"now": &now, "zero": &zero},
+		}, options)
+		assert.Nil(t, err)
+		assert.Equal(t, pointers, values)
+
+		var any interface{}
+		data, err := disorder.Marshal(zero, options)
+		assert.Nil(t, err)
+		err = disorder.Unmarshal(data, &any)
+		assert.Nil(t, err)
+		assert.IsType(t, time.Time{}, any)
+	}
+}
+
+func TestNull(t *testing.T) {
+	numbers0 := []*Number{{Value: 1}, nil, {Value: 3}}
+	data, err := disorder.Marshal(numbers0)
+	assert.Nil(t, err)
+	var numbers1 []*Number
+	err = disorder.Unmarshal(data, &numbers1)
+	assert.Nil(t, err)
+	assert.Equal(t, numbers0, numbers1)
+
+	var nilNumber *Number
+	var values []interface{}
+	data, err = disorder.Marshal([]interface{}{"foo", nil, nilNumber, []int32(nil), int32(1)})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &values)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{"foo", nil, nil, nil, int32(1)}, values)
+
+	wrapper := NumberWrapper{Value: &Number{Value: 1}}
+	data, err = disorder.Marshal(NumberWrapper{})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &wrapper)
+	assert.Nil(t, err)
+	assert.NotNil(t, wrapper.Value)
+	data, err = disorder.Marshal(NumberWrapper{}, disorder.EncoderOptions{NullFields: true})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &wrapper)
+	assert.Nil(t, err)
+	assert.Nil(t, wrapper.Value)
+
+	var m map[string]*Number
+	data, err = disorder.Marshal(map[string]*Number{"nil": nil}, disorder.EncoderOptions{NullFields: true})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &m)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]*Number{"nil": nil}, m)
+
+	number := &Number{Value: 1}
+	data, err = disorder.Marshal([]*Number{nil})
+	assert.Nil(t, err)
+	i := 1
+	err = disorder.Unmarshal(data[1:len(data)-1], &number)
+	assert.Nil(t, err)
+	assert.Nil(t, number)
+	err = disorder.Unmarshal(data[1:len(data)-1], &i)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, i)
+
+	var skip SkipObject
+	data, err = disorder.Marshal(map[string]interface{}{"skipped": []*Number{nil}, "null": nilNumber}, disorder.EncoderOptions{NullFields: true})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &skip)
+	assert.Nil(t, err)
+}
+
+func TestStructTagOptions(t *testing.T) {
+	quantity := uint16(3)
+	options0 := Options{
+		Parent:   &Base{Version: 1},
+		Key:      "key",
+		Price:    9.99,
+		Enabled:  true,
+		Quantity: &quantity,
+		Base:     Base{Version: 2},
+		Audit:    &Audit{Creator: "foo"},
+	}
+	data, err := disorder.Marshal(&options0)
+	assert.Nil(t, err)
+	var fields map[string]interface{}
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"parent":   map[string]interface{}{"version": int32(1)},
+		"key":      "key",
+		"price":    "9.99",
+		"enabled":  "true",
+		"quantity": "3",
+		"version":  int32(2),
+		"creator":  "foo",
+	}, fields)
+	var options1 Options
+	err = disorder.Unmarshal(data, &options1)
+	assert.Nil(t, err)
+	assert.Equal(t, options0, options1)
+
+	data, err = disorder.Marshal(&Options{Count: 1, Name: "name", Tags: []string{}, Labels: map[string]int32{"a": 1}})
+	assert.Nil(t, err)
+	fields = nil
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"count":   int32(1),
+		"name":    "name",
+		"labels":  map[string]interface{}{"a": int32(1)},
+		"parent":  nil,
+		"key":     "",
+		"price":   "0",
+		"enabled": "false",
+		"version": int32(0),
+	}, fields)
+	options1 = Options{}
+	err = disorder.Unmarshal(data, &options1)
+	assert.Nil(t, err)
+	assert.Nil(t, options1.Parent)
+	assert.Nil(t, options1.Audit)
+
+	data, err = disorder.Marshal(map[string]interface{}{"key": "key"})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &options1)
+	assert.NotNil(t, err)
+	data, err = disorder.Marshal(map[string]interface{}{"parent": nil, "key": "key", "price": 1.5}, disorder.EncoderOptions{NullFields: true})
+	assert.Nil(t, err)
+	options1 = Options{}
+	err = disorder.Unmarshal(data, &options1)
+	assert.Nil(t, err)
+	assert.Equal(t, 1.5, options1.Price)
+	data, err = disorder.Marshal(map[string]interface{}{"parent": nil, "key": "key", "enabled": "yes"}, disorder.EncoderOptions{NullFields: true})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &options1)
+	assert.NotNil(t, err)
+
+	_, err = disorder.Marshal(&RecursiveInline{})
+	assert.NotNil(t, err)
+	_, err = disorder.Marshal(&InvalidInline{})
+	assert.NotNil(t, err)
+	data, err = disorder.Marshal(&ShadowedInline{Version: 1, Base: Base{Version: 2}})
+	assert.Nil(t, err)
+	var shadowed ShadowedInline
+	err = disorder.Unmarshal(data, &shadowed)
+	assert.Nil(t, err)
+	assert.Equal(t, ShadowedInline{Version: 1}, shadowed)
+}
+
+func TestEmbeddedStructs(t *testing.T) {
+	createdAt := time.UnixMilli(time.Now().UnixMilli())
+	user0 := User{
+		Entity:  Entity{ID: 1, CreatedAt: createdAt},
+		Profile: &Profile{Email: "foo@bar.com"},
+		ID:      "user",
+		Name:    "foo",
+	}
+	data, err := disorder.Marshal(&user0)
+	assert.Nil(t, err)
+	var fields map[string]interface{}
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id":         "user",
+		"created_at": createdAt,
+		"email":      "foo@bar.com",
+		"name":       "foo",
+	}, fields)
+	var user1 User
+	err = disorder.Unmarshal(data, &user1)
+	assert.Nil(t, err)
+	user0.Entity.ID = 0
+	assert.Equal(t, user0, user1)
+
+	data, err = disorder.Marshal(&User{Name: "foo"}, disorder.EncoderOptions{NullFields: true})
+	assert.Nil(t, err)
+	fields = nil
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.NotContains(t, fields, "email")
+	user1 = User{}
+	err = disorder.Unmarshal(data, &user1)
+	assert.Nil(t, err)
+	assert.Nil(t, user1.Profile)
+
+	data, err = disorder.Marshal(&Named{Entity: Entity{ID: 1, CreatedAt: createdAt}})
+	assert.Nil(t, err)
+	fields = nil
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"entity": map[string]interface{}{"id": int64(1), "created_at": createdAt}}, fields)
+
+	data, err = disorder.Marshal(&Conflict{Left: Left{X: 1, Left: 2}, Right: Right{X: 3}})
+	assert.Nil(t, err)
+	fields = nil
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"left": int32(2)}, fields)
+
+	_, err = disorder.Marshal(&DuplicatedKeys{})
+	assert.NotNil(t, err)
+	// A tagged direct field does not win over an untagged one with the same key
+	_, err = disorder.Marshal(&DuplicatedNames{})
+	assert.EqualError(t, err, "duplicated key 'Name' in struct disorder_test.DuplicatedNames")
+	err = disorder.Unmarshal([]byte{23, 24}, &DuplicatedNames{})
+	assert.NotNil(t, err)
+}
+
+func TestUnknownFields(t *testing.T) {
+	object0 := newObject()
+	for _, options := range []disorder.EncoderOptions{{}, {Compact: true}, {InternKeys: true}} {
+		objects := []Object{object0, object0}
+		data, err := disorder.Marshal(objects, options)
+		assert.Nil(t, err)
+		decoder := disorder.NewDecoder(bytes.NewBuffer(data))
+		var partials []PartialObject
+		err = decoder.Decode(&partials)
+		assert.Nil(t, err)
+		assert.Equal(t, 24, len(decoder.Warnings()))
+		assert.Equal(t, int32(123), partials[1].IntField)
+		assert.NotEmpty(t, partials[1].Unknown)
+
+		for _, forward := range []disorder.EncoderOptions{{}, {InternKeys: true}} {
+			data, err = disorder.Marshal(partials, forward)
+			assert.Nil(t, err)
+			var result []Object
+			err = disorder.Unmarshal(data, &result)
+			assert.Nil(t, err)
+			assert.Equal(t, objects, result)
+		}
+	}
+
+	partial := PartialObject{Unknown: disorder.Unknown{1}}
+	data, err := disorder.Marshal(map[string]int32{"int_field": 1})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &partial)
+	assert.Nil(t, err)
+	assert.Equal(t, PartialObject{IntField: 1}, partial)
+
+	// A hostile length of a captured field is limited and allocates nothing up front
+	hostile := []byte{23, 6, 1, 'x', 0xff, 0xff, 0xff, 0xff}
+	decoder := disorder.NewDecoder(bytes.NewBuffer(hostile), disorder.DecoderOptions{MaxTotalBytes: 1024})
+	err = decoder.Decode(&partial)
+	assert.True(t, errors.Is(err, disorder.ErrLimitExceeded))
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err = disorder.Unmarshal(hostile, &partial)
+	runtime.ReadMemStats(&after)
+	assert.True(t, errors.Is(err, disorder.ErrUnexpectedEOF))
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20))
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	data, err := disorder.Marshal(map[string]int32{"x": 5, "z": 6})
+	assert.Nil(t, err)
+	var point Point
+	decoder := disorder.NewDecoder(bytes.NewBuffer(data))
+	err = decoder.Decode(&point)
+	assert.Nil(t, err)
+	assert.Equal(t, Point{X: 5}, point)
+	assert.Equal(t, 1, len(decoder.Warnings()))
+	var warning *disorder.UnknownFieldError
+	assert.True(t, errors.As(decoder.Warnings()[0], &warning))
+	assert.Equal(t, reflect.TypeOf(point), warning.Type)
+	assert.Equal(t, "z", warning.Key)
+	assert.Equal(t, "int", warning.Tag)
+
+	point = Point{}
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{DisallowUnknownFields: true})
+	err = decoder.Decode(&point)
+	assert.True(t, errors.Is(err, disorder.ErrUnknownField))
+
+	object := newObject()
+	data, err = disorder.Marshal(&object)
+	assert.Nil(t, err)
+	var skip SkipObject
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data))
+	err = decoder.Decode(&skip)
+	assert.Nil(t, err)
+	assert.Equal(t, 12, len(decoder.Warnings()))
+	var first *disorder.UnknownFieldError
+	assert.True(t, errors.As(decoder.Warnings()[0], &first))
+	assert.Equal(t, reflect.TypeOf(skip), first.Type)
+	assert.Equal(t, "boolean_field", first.Key)
+	assert.Equal(t, "bool", first.Tag)
+	assert.Equal(t, int64(1), first.Offset)
+
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{DisallowUnknownFields: true})
+	err = decoder.Decode(&skip)
+	assert.True(t, errors.As(err, &first))
+	assert.Equal(t, "boolean_field", first.Key)
+	assert.Empty(t, decoder.Warnings())
+
+	var partial PartialObject
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data))
+	err = decoder.Decode(&partial)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(123), partial.IntField)
+	assert.Equal(t, 12, len(decoder.Warnings()))
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{DisallowUnknownFields: true})
+	err = decoder.Decode(&partial)
+	assert.True(t, errors.Is(err, disorder.ErrUnknownField))
+}
+
+func TestDecodeError(t *testing.T) {
+	data, err := disorder.Marshal(map[string]interface{}{
+		"nested": map[string]interface{}{
+			"key0": map[string]interface{}{
+				"key1": []interface{}{[]interface{}{map[string]interface{}{"key2": "purple"}}},
+			},
+		},
+	})
+	assert.Nil(t, err)
+	var object Object
+	err = disorder.Unmarshal(data, &object)
+	var decodeErr *disorder.DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, `Object.nested["key0"]["key1"][0][0]["key2"]`, decodeErr.Path)
+	assert.Equal(t, "*disorder_test.Color", decodeErr.Type)
+	assert.Equal(t, "string", decodeErr.Tag)
+	// the key2 field is followed by 6 end tags: tag, key length, key, string length and string
+	assert.Equal(t, int64(len(data)-6-(1+1+4+4+6)), decodeErr.Offset)
+
+	data, err = disorder.Marshal([]map[string]int64{{"value": 1}})
+	assert.Nil(t, err)
+	var numbers []Number
+	err = disorder.Unmarshal(data, &numbers)
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "[0].value", decodeErr.Path)
+	assert.Equal(t, "int32", decodeErr.Type)
+	assert.Equal(t, "long", decodeErr.Tag)
+	assert.Equal(t, "decode [0].value failed at offset 2: type mismatch: assign int64 to int32", err.Error())
+
+	data, err = disorder.Marshal(map[string]interface{}{"parent": []int32{1}})
+	assert.Nil(t, err)
+	var options Options
+	err = disorder.Unmarshal(data, &options)
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "Options.parent", decodeErr.Path)
+	assert.Equal(t, "disorder_test.Base", decodeErr.Type)
+	assert.Equal(t, "array", decodeErr.Tag)
+
+	data, err = disorder.Marshal(map[string]int32{"x": 1})
+	assert.Nil(t, err)
+	var point Point
+	err = disorder.Unmarshal(data[:len(data)-2], &point)
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "Point.x", decodeErr.Path)
+	assert.True(t, errors.Is(err, disorder.ErrUnexpectedEOF))
+}
+
+func TestDecodeModes(t *testing.T) {
+	data, err := disorder.Marshal(map[string]interface{}{
+		"int_array": []int32{4, 5},
+		"int_map":   map[string]int32{"b": 2},
+	})
+	assert.Nil(t, err)
+	existing := func() *Object {
+		return &Object{
+			StringField: "foo",
+			IntArray:    []int32{1, 2, 3},
+			IntMap:      map[string]int32{"a": 1},
+		}
+	}
+
+	merged := existing()
+	err = disorder.NewDecoder(bytes.NewBuffer(data)).Decode(merged)
+	assert.Nil(t, err)
+	assert.Equal(t, &Object{
+		StringField: "foo",
+		IntArray:    []int32{4, 5},
+		IntMap:      map[string]int32{"a": 1, "b": 2},
+	}, merged)
+
+	replaced := existing()
+	err = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{Mode: disorder.DecodeReplace}).Decode(replaced)
+	assert.Nil(t, err)
+	assert.Equal(t, &Object{
+		IntArray: []int32{4, 5},
+		IntMap:   map[string]int32{"b": 2},
+	}, replaced)
+
+	appended := existing()
+	err = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{Mode: disorder.DecodeAppend}).Decode(appended)
+	assert.Nil(t, err)
+	assert.Equal(t, &Object{
+		StringField: "foo",
+		IntArray:    []int32{1, 2, 3, 4, 5},
+		IntMap:      map[string]int32{"a": 1, "b": 2},
+	}, appended)
+
+	var empty []int32
+	data, err = disorder.Marshal([]int32{})
+	assert.Nil(t, err)
+	err = disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{Mode: disorder.DecodeAppend}).Decode(&empty)
+	assert.Nil(t, err)
+	assert.Equal(t, []int32{}, empty)
+}
+
+func TestCanonical(t *testing.T) {
+	object := newObject()
+	object.IntMap = map[string]int32{}
+	for i := 0; i < 32; i++ {
+		object.IntMap[fmt.Sprint(i)] = int32(i)
+	}
+	data0, err := disorder.Canonical(&object)
+	assert.Nil(t, err)
+	for i := 0; i < 16; i++ {
+		data1, err := disorder.Canonical(&object)
+		assert.Nil(t, err)
+		assert.Equal(t, data0, data1)
+		data1, err = disorder.Marshal(&object, disorder.EncoderOptions{Canonical: true, Compact: true, InternKeys: true})
+		assert.Nil(t, err)
+		data2, err := disorder.Marshal(&object, disorder.EncoderOptions{Canonical: true, Compact: true, InternKeys: true})
+		assert.Nil(t, err)
+		assert.Equal(t, data1, data2)
+	}
+	var result Object
+	err = disorder.Unmarshal(data0, &result)
+	assert.Nil(t, err)
+	assert.Equal(t, object, result)
+
+	data0, err = disorder.Canonical(map[string]int32{"b": 2, "a": 1, "B": 3, "ab": 4})
+	assert.Nil(t, err)
+	var keys Keys
+	err = disorder.Unmarshal(data0, &keys)
+	assert.Nil(t, err)
+	assert.Equal(t, Keys{"B", "a", "ab", "b"}, keys)
+}
+
+func TestMapKeys(t *testing.T) {
+	keys0 := MapKeys{
+		Users:    map[int64]*Profile{-1: {Email: "foo"}, 1 << 40: {Email: "bar"}},
+		Colors:   map[Color]int32{ColorRed: 1, ColorBlue: 3},
+		Versions: map[Version]string{{1, 2}: "foo", {10, 0}: "bar"},
+		Counts:   map[uint8]bool{255: true},
+	}
+	data, err := disorder.Marshal(&keys0)
+	assert.Nil(t, err)
+	var keys1 MapKeys
+	err = disorder.Unmarshal(data, &keys1)
+	assert.Nil(t, err)
+	assert.Equal(t, keys0, keys1)
+
+	var generic map[string]map[string]interface{}
+	err = disorder.Unmarshal(data, &generic)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"-1": map[string]interface{}{"email": "foo"}, "1099511627776": map[string]interface{}{"email": "bar"}}, generic["users"])
+	assert.Equal(t, map[string]interface{}{"red": int32(1), "blue": int32(3)}, generic["colors"])
+	assert.Equal(t, map[string]interface{}{"1.2": "foo", "10.0": "bar"}, generic["versions"])
+
+	data, err = disorder.Canonical(map[int32]int32{10: 0, 9: 1, -1: 2})
+	assert.Nil(t, err)
+	var keys Keys
+	err = disorder.Unmarshal(data, &keys)
+	assert.Nil(t, err)
+	assert.Equal(t, Keys{"-1", "10", "9"}, keys)
+
+	revisions0 := map[Revision]int32{{3}: 1, {10}: 2}
+	data, err = disorder.Marshal(revisions0)
+	assert.Nil(t, err)
+	var revisions1 map[Revision]int32
+	err = disorder.Unmarshal(data, &revisions1)
+	assert.Nil(t, err)
+	assert.Equal(t, revisions0, revisions1)
+	key, err := disorder.MarshalKey(Revision{7})
+	assert.Nil(t, err)
+	assert.Equal(t, "r7", key)
+
+	_, err = disorder.Marshal(map[float64]int32{1: 1})
+	assert.NotNil(t, err)
+	_, err = disorder.Marshal(map[Color]int32{{}: 1})
+	assert.NotNil(t, err)
+
+	data, err = disorder.Marshal(map[string]int32{"256": 1})
+	assert.Nil(t, err)
+	var counts map[uint8]int32
+	err = disorder.Unmarshal(data, &counts)
+	var decodeErr *disorder.DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, `["256"]`, decodeErr.Path)
+	assert.Equal(t, "uint8", decodeErr.Type)
+	var floats map[float64]int32
+	err = disorder.Unmarshal(data, &floats)
+	assert.NotNil(t, err)
+
+	name, err := disorder.MarshalKey(Version{3, 4})
+	assert.Nil(t, err)
+	assert.Equal(t, "3.4", name)
+	var version Version
+	err = disorder.UnmarshalKey(name, &version)
+	assert.Nil(t, err)
+	assert.Equal(t, Version{3, 4}, version)
+}
+
+func TestPointers(t *testing.T) {
+	i := int32(1)
+	str := "foo"
+	number := &Number{Value: 2}
+	slice := []int32{3, 4}
+	long := int64(5)
+	m := map[string]*int64{"five": &long}
+	color := &ColorGreen
+	now := time.UnixMilli(time.Now().UnixMilli())
+	timestamp := &now
+	element := &i
+	pointers0 := Pointers{
+		Int:      &i,
+		String:   &str,
+		Nested:   &number,
+		Slice:    &slice,
+		Map:      &m,
+		Color:    &color,
+		Time:     &timestamp,
+		Nil:      new(*Number),
+		Elements: []**int32{&element, nil, new(*int32)},
+	}
+	data, err := disorder.Marshal(&pointers0)
+	assert.Nil(t, err)
+	var pointers1 Pointers
+	err = disorder.Unmarshal(data, &pointers1)
+	assert.Nil(t, err)
+	assert.Equal(t, i, *pointers1.Int)
+	assert.Equal(t, str, *pointers1.String)
+	assert.Equal(t, *number, **pointers1.Nested)
+	assert.Equal(t, slice, *pointers1.Slice)
+	assert.Equal(t, long, *(*pointers1.Map)["five"])
+	assert.Equal(t, ColorGreen, **pointers1.Color)
+	assert.Equal(t, now, **pointers1.Time)
+	assert.Nil(t, pointers1.Nil)
+	assert.Equal(t, 3, len(pointers1.Elements))
+	assert.Equal(t, i, **pointers1.Elements[0])
+	assert.Nil(t, pointers1.Elements[1])
+	assert.Nil(t, pointers1.Elements[2])
+
+	var fields map[string]interface{}
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), fields["int"])
+	assert.Equal(t, []interface{}{int32(3), int32(4)}, fields["slice"])
+	assert.NotContains(t, fields, "nil")
+	assert.Equal(t, []interface{}{int32(1), nil, nil}, fields["elements"])
+
+	existing := int32(0)
+	target := &existing
+	data, err = disorder.Marshal(int32(6))
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &target)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(6), existing)
+}
+
+func TestArrays(t *testing.T) {
+	arrays0 := Arrays{
+		UUID:    [16]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16},
+		Vector:  [3]float64{1.5, 2.5, 3.5},
+		Points:  [2]*Point{{X: 1, Y: 2}, nil},
+		Matrix:  [2][2]int32{{1, 2}, {3, 4}},
+		Pointer: &[4]byte{4, 3, 2, 1},
+	}
+	data, err := disorder.Marshal(&arrays0)
+	assert.Nil(t, err)
+	var arrays1 Arrays
+	err = disorder.Unmarshal(data, &arrays1)
+	assert.Nil(t, err)
+	assert.Equal(t, arrays0, arrays1)
+
+	var fields map[string]interface{}
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, arrays0.UUID[:], fields["uuid"])
+	assert.Equal(t, []interface{}{1.5, 2.5, 3.5}, fields["vector"])
+	assert.Equal(t, []interface{}{[]interface{}{int32(1), int32(2)}, []interface{}{int32(3), int32(4)}}, fields["matrix"])
+	assert.NotContains(t, fields, "empty")
+
+	var vector [2]float64
+	data, err = disorder.Marshal([]float64{1, 2, 3})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &vector)
+	assert.NotNil(t, err)
+
+	// Reading stops at the first element beyond the array
+	long, err := disorder.Marshal(make([]float64, 1000))
+	assert.Nil(t, err)
+	decoder := disorder.NewDecoder(bytes.NewReader(long))
+	err = decoder.Decode(&vector)
+	assert.EqualError(t, err, "decode [2] failed at offset 19: more than 2 elements for [2]float64")
+	assert.Equal(t, int64(20), decoder.Offset())
+
+	var vector3 [3]float64
+	err = disorder.Unmarshal(data, &vector3)
+	assert.Nil(t, err)
+	assert.Equal(t, [3]float64{1, 2, 3}, vector3)
+
+	data, err = disorder.Marshal(map[string]interface{}{"uuid": []byte{1, 2}})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &arrays1)
+	var decodeErr *disorder.DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "Arrays.uuid", decodeErr.Path)
+	assert.Equal(t, "[16]uint8", decodeErr.Type)
+}
+
+func TestRegisterCodec(t *testing.T) {
+	moneyType := reflect.TypeOf(Money{})
+	disorder.RegisterCodec(moneyType, func(e *disorder.Encoder, name string, value interface{}) error {
+		return e.WriteLong(name, value.(Money).cents)
+	}, func(d *disorder.Decoder, value interface{}) error {
+		return d.ReadLong(&value.(*Money).cents)
+	})
+	disorder.RegisterCodec(reflect.TypeOf(net.IP{}), func(e *disorder.Encoder, name string, value interface{}) error {
+		return e.WriteString(name, value.(net.IP).String())
+	}, func(d *disorder.Decoder, value interface{}) error {
+		var s string
+		if err := d.ReadString(&s); err != nil {
+			return err
+		}
+		*value.(*net.IP) = net.ParseIP(s)
+		return nil
+	})
+
+	account0 := Account{
+		Balance: Money{cents: 123},
+		Limit:   &Money{cents: 456},
+		History: []Money{{cents: 1}, {cents: 2}},
+		Address: net.ParseIP("10.0.0.1"),
+		Budgets: map[string]Money{"food": {cents: 789}},
+	}
+	data, err := disorder.Marshal(&account0)
+	assert.Nil(t, err)
+	var account1 Account
+	err = disorder.Unmarshal(data, &account1)
+	assert.Nil(t, err)
+	assert.Equal(t, account0, account1)
+
+	var fields map[string]interface{}
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(123), fields["balance"])
+	assert.Equal(t, []interface{}{int64(1), int64(2)}, fields["history"])
+	assert.Equal(t, "10.0.0.1", fields["address"])
+
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer)
+	encoder.RegisterEncoder(moneyType, func(e *disorder.Encoder, name string, value interface{}) error {
+		cents := value.(Money).cents
+		return e.WriteString(name, fmt.Sprintf("%d.%02d", cents/100, cents%100))
+	})
+	encoder.RegisterEncoder(reflect.TypeOf(net.IP{}), nil)
+	err = encoder.Encode(&account0)
+	assert.Nil(t, err)
+	err = encoder.Flush()
+	assert.Nil(t, err)
+	data = buffer.Bytes()
+	fields = nil
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, "1.23", fields["balance"])
+	// Without the codec net.IP falls back to its TextMarshaler
+	assert.Equal(t, "10.0.0.1", fields["address"])
+
+	err = disorder.Unmarshal(data, &account1)
+	assert.NotNil(t, err)
+	decoder := disorder.NewDecoder(bytes.NewBuffer(data))
+	decoder.RegisterDecoder(moneyType, func(d *disorder.Decoder, value interface{}) error {
+		var s string
+		if err := d.ReadString(&s); err != nil {
+			return err
+		}
+		var units, cents int64
+		_, err := fmt.Sscanf(s, "%d.%d", &units, &cents)
+		value.(*Money).cents = units*100 + cents
+		return err
+	})
+	decoder.RegisterDecoder(reflect.TypeOf(net.IP{}), nil)
+	account1 = Account{}
+	err = decoder.Decode(&account1)
+	assert.Nil(t, err)
+	assert.Equal(t, account0, account1)
+}
+
+func TestTextAndBinaryMarshalers(t *testing.T) {
+	markers0 := Markers{
+		Big:      big.NewInt(0).Lsh(big.NewInt(1), 100),
+		Version:  Version{1, 2},
+		Versions: []Version{{3, 4}},
+		Blob:     Blob{1, 2, 3},
+		Both:     Both{Value: "foo"},
+		Color:    ColorRed,
+	}
+	data, err := disorder.Marshal(&markers0)
+	assert.Nil(t, err)
+	var markers1 Markers
+	err = disorder.Unmarshal(data, &markers1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, markers0.Big.Cmp(markers1.Big))
+	assert.Equal(t, markers0.Version, markers1.Version)
+	assert.Equal(t, markers0.Versions, markers1.Versions)
+	assert.Equal(t, markers0.Blob, markers1.Blob)
+	assert.Equal(t, markers0.Both, markers1.Both)
+	assert.Equal(t, markers0.Color, markers1.Color)
+
+	var fields map[string]interface{}
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, "1267650600228229401496703205376", fields["big"])
+	assert.Equal(t, "1.2", fields["version"])
+	assert.Equal(t, []interface{}{"3.4"}, fields["versions"])
+	assert.Equal(t, []byte{3, 1, 2, 3}, fields["blob"])
+	// TextMarshaler wins over BinaryMarshaler and Enum wins over both
+	assert.Equal(t, "text:foo", fields["both"])
+	assert.Equal(t, disorder.EnumValue("red"), fields["color"])
+
+	// Objects written before the fallback still decode by reflection
+	data, err = disorder.Marshal(map[string]interface{}{"version": map[string]int64{"Major": 5, "Minor": 6}})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &markers1)
+	assert.Nil(t, err)
+	assert.Equal(t, Version{5, 6}, markers1.Version)
+
+	data, err = disorder.Marshal(map[string]string{"version": "foo"})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &markers1)
+	var decodeErr *disorder.DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, "Markers.version", decodeErr.Path)
+
+	// Pointer receivers are used for values which are not addressable
+	type holder struct {
+		N big.Int `disorder:"n"`
+	}
+	data, err = disorder.Marshal(holder{N: *big.NewInt(42)})
+	assert.Nil(t, err)
+	fields = nil
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"n": "42"}, fields)
+
+	data, err = disorder.Marshal(map[string]big.Int{"a": *big.NewInt(7)})
+	assert.Nil(t, err)
+	var ints map[string]big.Int
+	err = disorder.Unmarshal(data, &ints)
+	assert.Nil(t, err)
+	n := ints["a"]
+	assert.Equal(t, int64(7), n.Int64())
+}
+
+func TestEncodeStreaming(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer)
+	assert.Nil(t, encoder.Encode(&Rows{Count: 10000}))
+	// Rows are flushed while the marshaler writes them
+	assert.NotZero(t, buffer.Len())
+	assert.Nil(t, encoder.Flush())
+	var rows []Number
+	err := disorder.Unmarshal(buffer.Bytes(), &rows)
+	assert.Nil(t, err)
+	assert.Equal(t, 10000, len(rows))
+	assert.Equal(t, int32(9999), rows[9999].Value)
+}
+
+func TestEncodeRollback(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer, disorder.EncoderOptions{InternKeys: true})
+	assert.Nil(t, encoder.Encode(map[string]interface{}{"b": int32(0)}))
+	assert.NotNil(t, encoder.Encode(map[string]interface{}{"a": complex64(1)}))
+	assert.NotNil(t, encoder.Encode([]interface{}{strings.Repeat("x", 8192), complex64(1)}))
+	assert.Zero(t, buffer.Len())
+	assert.Nil(t, encoder.Encode(int32(1)))
+	assert.Nil(t, encoder.Encode(map[string]interface{}{"a": int32(2), "b": int32(3)}))
+	assert.Nil(t, encoder.Flush())
+
+	decoder := disorder.NewDecoder(buffer)
+	var first map[string]int32
+	assert.Nil(t, decoder.Decode(&first))
+	assert.Equal(t, map[string]int32{"b": 0}, first)
+	var i int32
+	assert.Nil(t, decoder.Decode(&i))
+	assert.Equal(t, int32(1), i)
+	var last map[string]int32
+	assert.Nil(t, decoder.Decode(&last))
+	assert.Equal(t, map[string]int32{"a": 2, "b": 3}, last)
+}
+
+func TestEncodePartialFlush(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer)
+	values := make([]interface{}, 2001)
+	for i := 0; i < 2000; i++ {
+		values[i] = int32(i)
+	}
+	values[2000] = complex128(1)
+	err := encoder.Encode(values)
+	assert.EqualError(t, err, "unsupported type: complex128")
+	assert.NotZero(t, buffer.Len())
+	written := buffer.Len()
+
+	// Part of the array is written out, so the encoder stays broken
+	assert.Equal(t, err, encoder.Encode(int32(7)))
+	assert.Equal(t, err, encoder.WriteInt("", 7))
+	assert.Equal(t, err, encoder.WriteNullField("key"))
+	assert.Equal(t, err, encoder.WriteRaw("", disorder.RawMessage{16}))
+	assert.Equal(t, err, encoder.BeginArray(""))
+	assert.Equal(t, err, encoder.EndArray())
+	assert.Equal(t, err, encoder.BeginObject(""))
+	assert.Equal(t, err, encoder.EndObject())
+	assert.Equal(t, err, encoder.Flush())
+	assert.Equal(t, written, buffer.Len())
+}
+
+func TestStreaming(t *testing.T) {
+	buffer := &bytes.Buffer{}
+	encoder := disorder.NewEncoder(buffer, disorder.EncoderOptions{InternKeys: true})
+	assert.Nil(t, encoder.BeginObject(""))
+	assert.Nil(t, encoder.WriteField("kind", "rows"))
+	assert.Nil(t, encoder.WriteField("missing", nil))
+	assert.Nil(t, encoder.BeginArray("rows"))
+	rows := 10000
+	for i := 0; i < rows; i++ {
+		assert.Nil(t, encoder.WriteValue("", &Number{Value: int32(i)}))
+	}
+	// Rows are flushed while the array is open
+	assert.NotZero(t, buffer.Len())
+	assert.Nil(t, encoder.EndArray())
+	assert.Nil(t, encoder.BeginObject("counts"))
+	assert.Nil(t, encoder.WriteField("rows", rows))
+	assert.Nil(t, encoder.EndObject())
+	assert.Nil(t, encoder.EndObject())
+	assert.Nil(t, encoder.Flush())
+
+	var result struct {
+		Kind   string           `disorder:"kind"`
+		Rows   []Number         `disorder:"rows"`
+		Counts map[string]int64 `disorder:"counts"`
+	}
+	err := disorder.Unmarshal(buffer.Bytes(), &result)
+	assert.Nil(t, err)
+	assert.Equal(t, "rows", result.Kind)
+	assert.Equal(t, rows, len(result.Rows))
+	assert.Equal(t, int32(rows-1), result.Rows[rows-1].Value)
+	assert.Equal(t, map[string]int64{"rows": int64(rows)}, result.Counts)
+
+	encoder = disorder.NewEncoder(&bytes.Buffer{})
+	assert.EqualError(t, encoder.EndArray(), "EndArray called outside any array or object")
+	assert.EqualError(t, encoder.WriteField("key", 1), "WriteField called outside an object")
+	assert.NotNil(t, encoder.WriteInt("key", 1))
+	assert.Nil(t, encoder.BeginArray(""))
+	assert.EqualError(t, encoder.EndObject(), "EndObject called inside an array")
+	assert.EqualError(t, encoder.WriteField("key", 1), "WriteField called outside an object")
+	assert.NotNil(t, encoder.WriteInt("key", 1))
+	assert.Nil(t, encoder.BeginObject(""))
+	assert.EqualError(t, encoder.EndArray(), "EndArray called inside an object")
+	assert.NotNil(t, encoder.WriteInt("", 1))
+	assert.Nil(t, encoder.EndObject())
+	assert.Nil(t, encoder.EndArray())
+	assert.NotNil(t, encoder.EndArray())
+
+	// A failed WriteArray or WriteObject leaves its container
+	encoder = disorder.NewEncoder(&bytes.Buffer{})
+	assert.Nil(t, encoder.BeginObject(""))
+	assert.NotNil(t, encoder.WriteArray("list", func() error {
+		return encoder.WriteObject("", func() error {
+			return fmt.Errorf("failed")
+		})
+	}))
+	assert.Nil(t, encoder.WriteField("key", 1))
+	assert.Nil(t, encoder.EndObject())
+	assert.EqualError(t, encoder.EndObject(), "EndObject called outside any array or object")
+}
+
+func TestRawMessage(t *testing.T) {
+	object := newObject()
+	body, err := disorder.Marshal(&object)
+	assert.Nil(t, err)
+	data, err := disorder.Marshal(&Envelope{Kind: "object", Body: body})
+	assert.Nil(t, err)
+	var envelope Envelope
+	err = disorder.Unmarshal(data, &envelope)
+	assert.Nil(t, err)
+	assert.Equal(t, "object", envelope.Kind)
+	assert.Equal(t, disorder.RawMessage(body), envelope.Body)
+	forwarded, err := disorder.Marshal(&envelope)
+	assert.Nil(t, err)
+	assert.Equal(t, data, forwarded)
+	var result Object
+	err = disorder.Unmarshal(envelope.Body, &result)
+	assert.Nil(t, err)
+	assert.Equal(t, object, result)
+
+	for _, options := range []disorder.EncoderOptions{{InternKeys: true}, {InternKeys: true, Compact: true}} {
+		envelopes0 := []ObjectEnvelope{{Kind: "a", Body: object}, {Kind: "b", Body: object}}
+		data, err = disorder.Marshal(envelopes0, options)
+		assert.Nil(t, err)
+		var raws []Envelope
+		err = disorder.Unmarshal(data, &raws)
+		assert.Nil(t, err)
+		err = disorder.Unmarshal(raws[1].Body, &result)
+		assert.Nil(t, err)
+		assert.Equal(t, object, result)
+		data, err = disorder.Marshal(raws, options)
+		assert.Nil(t, err)
+		var envelopes1 []ObjectEnvelope
+		err = disorder.Unmarshal(data, &envelopes1)
+		assert.Nil(t, err)
+		assert.Equal(t, envelopes0, envelopes1)
+	}
+
+	var values []disorder.RawMessage
+	data, err = disorder.Marshal([]interface{}{int32(1), "two", nil})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(data, &values)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(values))
+	var s string
+	err = disorder.Unmarshal(values[1], &s)
+	assert.Nil(t, err)
+	assert.Equal(t, "two", s)
+	data, err = disorder.Marshal(values)
+	assert.Nil(t, err)
+	var any []interface{}
+	err = disorder.Unmarshal(data, &any)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{int32(1), "two", nil}, any)
+
+	_, err = disorder.Marshal(map[string]disorder.RawMessage{"empty": {}})
+	assert.NotNil(t, err)
+
+	// A raw message must hold exactly one complete value
+	one, err := disorder.Marshal(int32(1))
+	assert.Nil(t, err)
+	for _, raw := range []disorder.RawMessage{{0}, {22}, {24}, {25, 0, 2}, {0x80 | 21}, {21}, append(one, 0, 0, 0, 0)} {
+		_, err = disorder.Marshal([]interface{}{raw})
+		assert.NotNil(t, err, "%v", raw)
+	}
+	data, err = disorder.Marshal([]interface{}{disorder.RawMessage(one), disorder.RawMessage{16}})
+	assert.Nil(t, err)
+	any = nil
+	err = disorder.Unmarshal(data, &any)
+	assert.Nil(t, err)
+	assert.Equal(t, []interface{}{int32(1), nil}, any)
+
+	// Key references of a message marshaled on its own are written in full when spliced
+	interned := disorder.EncoderOptions{InternKeys: true}
+	inner := []map[string]int32{{"a": 1}, {"a": 2}}
+	body, err = disorder.Marshal(inner, interned)
+	assert.Nil(t, err)
+	for _, options := range []disorder.EncoderOptions{{}, interned} {
+		data, err = disorder.Marshal([]interface{}{map[string]int32{"x": 0}, disorder.RawMessage(body), map[string]int32{"a": 3}}, options)
+		assert.Nil(t, err)
+		var outer []interface{}
+		err = disorder.Unmarshal(data, &outer)
+		assert.Nil(t, err)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"x": int32(0)},
+			[]interface{}{map[string]interface{}{"a": int32(1)}, map[string]interface{}{"a": int32(2)}},
+			map[string]interface{}{"a": int32(3)},
+		}, outer)
+	}
+	// A reference missing from the table of the message is an error
+	_, err = disorder.Marshal([]disorder.RawMessage{body[10:19]})
+	assert.Equal(t, []byte{23, 25, 0, 2, 0, 0, 0, 2, 24}, []byte(body[10:19]))
+	assert.NotNil(t, err)
+}
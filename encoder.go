@@ -6,207 +6,753 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"sort"
+	"strconv"
 	"time"
 )
 
+// bufferSize is the buffered size which triggers a write to the underlying writer.
+const bufferSize = 4096
+
+// keyTableSize bounds the key table of a stream, keys seen after the table is full are never interned.
+const keyTableSize = 1024
+
+// TimeFormat selects the encoding of time.Time values.
+type TimeFormat byte
+
+const (
+	// TimeMillis encodes time.Time as timestamp, milliseconds since 1970 decoded in local time.
+	TimeMillis TimeFormat = iota
+	// TimeNanos encodes time.Time as timestamp_ns, seconds and nanoseconds since 1970
+	// with the UTC offset of non UTC times.
+	TimeNanos
+)
+
+// EncoderOptions changes the encoded form of the values, the decoder accepts all forms.
+type EncoderOptions struct {
+	// Compact writes integers, timestamps and the lengths of bytes and strings as varints,
+	// which is smaller for small values.
+	Compact bool
+	// InternKeys writes repeated object keys as an index into the key table of the stream.
+	// Every key written in full is added to the table, by the encoder and the decoder alike.
+	InternKeys bool
+	// Time selects the encoding of time.Time values written with WriteValue or by reflection.
+	Time TimeFormat
+	// NullFields writes null for nil struct fields and map values instead of leaving them out.
+	// Nil array elements are always written as null to keep the indices.
+	NullFields bool
+	// Canonical writes map keys in bytewise order, struct fields keep their declaration order,
+	// so equal values always encode to the same bytes.
+	Canonical bool
+}
+
+// Encoder buffers the encoded data, Flush must be called to write out the remaining bytes.
 type Encoder struct {
-	writer io.Writer
+	writer  io.Writer
+	options EncoderOptions
+	keys    map[string]int
+	// keyCount counts the keys added to the key table, including keys spliced in more than once.
+	keyCount int
+	buffer   []byte
+	scratch  [binary.MaxVarintLen64]byte
+	encoders map[reflect.Type]EncodeFunc
+	// containers holds the start tags of the arrays and objects being written, innermost last.
+	containers []tag
+	// flushes counts the writes to the underlying writer, a failed Encode is only
+	// taken back when none of it was written out.
+	flushes int
+	// err is the error of a failed write to the underlying writer or of an Encode that failed
+	// after part of its value was written out, the stream cannot be read past it so every later call returns it.
+	err error
 }
 
-func NewEncoder(w io.Writer) *Encoder {
-	return &Encoder{
+// NewEncoder returns an encoder buffering its output to w. Encode no longer writes to w by itself,
+// the data reaches w every 4096 bytes and on Flush, so callers of NewEncoder(w).Encode(v) must call Flush.
+func NewEncoder(w io.Writer, options ...EncoderOptions) *Encoder {
+	e := &Encoder{
 		writer: w,
+		buffer: make([]byte, 0, bufferSize),
+	}
+	if len(options) > 0 {
+		e.options = options[0]
+	}
+	return e
+}
+
+// SortKeys sorts map keys in canonical mode, for marshalers iterating maps themselves.
+func (e *Encoder) SortKeys(keys []string) {
+	if e.options.Canonical {
+		sort.Strings(keys)
+	}
+}
+
+// Flush writes the buffered data to the underlying writer. Like bufio.Writer the encoder keeps
+// a write error or a short write, and returns it from every later call.
+func (e *Encoder) Flush() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.writer == nil || len(e.buffer) == 0 {
+		return nil
+	}
+	n, err := e.writer.Write(e.buffer)
+	e.flushes++
+	if err == nil && n < len(e.buffer) {
+		err = io.ErrShortWrite
 	}
+	if err != nil {
+		e.err = err
+		return err
+	}
+	e.buffer = e.buffer[:0]
+	return nil
 }
 
 func (e *Encoder) Encode(value interface{}) error {
 	v := reflect.ValueOf(value)
+	if e.err != nil {
+		return e.err
+	}
 	if isNull(v) {
 		return fmt.Errorf("null value cannot be marshal")
 	}
-	return e.write("", v)
+	buffered, containers, keyCount, flushes := len(e.buffer), len(e.containers), e.keyCount, e.flushes
+	err := e.write("", v)
+	if err != nil {
+		e.containers = e.containers[:containers]
+		if e.flushes == flushes {
+			e.rollback(buffered, keyCount)
+		} else {
+			e.err = err
+		}
+	}
+	return err
 }
 
-func (e *Encoder) write(name string, value reflect.Value) error {
-	if isNull(value) {
-		return nil
+// rollback drops what was written after the buffer and the key table had the given lengths,
+// so the encoder can go on after a failed value.
+func (e *Encoder) rollback(buffered, keyCount int) {
+	e.buffer = e.buffer[:buffered]
+	for key, index := range e.keys {
+		if index >= keyCount {
+			delete(e.keys, key)
+		}
 	}
+	e.keyCount = keyCount
+}
 
-	t := tagUndefined
-	var bytes []byte
-	var err error
-
-	switch i := value.Interface().(type) {
-	case *time.Time:
-		t = tagTimestamp
-		bytes = make([]byte, 8)
-		binary.BigEndian.PutUint64(bytes, uint64(i.UnixMilli()))
+// WriteValue writes any supported value, using its Marshaler if implemented.
+func (e *Encoder) WriteValue(name string, value interface{}) error {
+	return e.write(name, reflect.ValueOf(value))
+}
 
-	case Enum:
-		t = tagEnum
-		var enum string
-		enum, err = i.GetValue()
-		if err != nil {
-			return err
-		}
-		bytes = []byte{byte(len(enum))}
-		bytes = append(bytes, []byte(enum)...)
+func (e *Encoder) WriteBool(name string, value bool) error {
+	err := e.writeHead(tagBool, name)
+	if err != nil {
+		return err
+	}
+	if value {
+		e.buffer = append(e.buffer, 1)
+	} else {
+		e.buffer = append(e.buffer, 0)
+	}
+	return nil
+}
 
-	default:
-		switch value.Kind() {
-		case reflect.Ptr:
-			if value.Elem().Kind() == reflect.Struct {
-				return e.writeObject(name, value.Elem())
-			}
+func (e *Encoder) WriteInt(name string, value int32) error {
+	err := e.writeHead(tagInt, name)
+	if err != nil {
+		return err
+	}
+	e.writeSigned(int64(value), 4)
+	return nil
+}
 
-		case reflect.Bool:
-			t = tagBool
-			bytes = make([]byte, 1)
-			if value.Bool() {
-				bytes[0] = 1
-			} else {
-				bytes[0] = 0
-			}
+func (e *Encoder) WriteLong(name string, value int64) error {
+	err := e.writeHead(tagLong, name)
+	if err != nil {
+		return err
+	}
+	e.writeSigned(value, 8)
+	return nil
+}
 
-		case reflect.Int32:
-			t = tagInt
-			bytes = make([]byte, 4)
-			binary.BigEndian.PutUint32(bytes, uint32(value.Int()))
-
-		case reflect.Int64:
-			t = tagLong
-			bytes = make([]byte, 8)
-			binary.BigEndian.PutUint64(bytes, uint64(value.Int()))
-
-		case reflect.Float32:
-			t = tagFloat
-			bytes = make([]byte, 4)
-			binary.BigEndian.PutUint32(bytes, math.Float32bits(float32(value.Float())))
-
-		case reflect.Float64:
-			t = tagDouble
-			bytes = make([]byte, 8)
-			binary.BigEndian.PutUint64(bytes, math.Float64bits(value.Float()))
-
-		case reflect.String:
-			t = tagString
-			bytes = make([]byte, 4)
-			str := value.String()
-			binary.BigEndian.PutUint32(bytes, uint32(len(str)))
-			bytes = append(bytes, []byte(str)...)
-
-		case reflect.Slice:
-			if value.Type().Elem().Kind() == reflect.Uint8 {
-				t = tagBytes
-				bytes = make([]byte, 4)
-				binary.BigEndian.PutUint32(bytes, uint32(value.Len()))
-				array := value.Interface().([]byte)
-				bytes = append(bytes, array...)
-			} else {
-				return e.writeArray(name, value)
-			}
+func (e *Encoder) WriteShort(name string, value int16) error {
+	err := e.writeHead(tagShort, name)
+	if err != nil {
+		return err
+	}
+	e.writeSigned(int64(value), 2)
+	return nil
+}
 
-		case reflect.Map:
-			return e.writeMap(name, value)
+func (e *Encoder) WriteUshort(name string, value uint16) error {
+	err := e.writeHead(tagUshort, name)
+	if err != nil {
+		return err
+	}
+	e.writeUnsigned(uint64(value), 2)
+	return nil
+}
 
-		case reflect.Struct:
-			return e.writeObject(name, value)
-		}
+func (e *Encoder) WriteUint(name string, value uint32) error {
+	err := e.writeHead(tagUint, name)
+	if err != nil {
+		return err
 	}
+	e.writeUnsigned(uint64(value), 4)
+	return nil
+}
 
+func (e *Encoder) WriteUlong(name string, value uint64) error {
+	err := e.writeHead(tagUlong, name)
 	if err != nil {
 		return err
 	}
-	if t == tagUndefined {
-		return fmt.Errorf("unsupported type: %s", value.Type().String())
+	e.writeUnsigned(value, 8)
+	return nil
+}
+
+func (e *Encoder) WriteFloat(name string, value float32) error {
+	err := e.writeHead(tagFloat, name)
+	if err != nil {
+		return err
 	}
-	err = e.writeTag(t)
+	e.writeUint32(math.Float32bits(value))
+	return nil
+}
+
+func (e *Encoder) WriteDouble(name string, value float64) error {
+	err := e.writeHead(tagDouble, name)
 	if err != nil {
 		return err
 	}
-	err = e.writeName(name)
+	e.writeUint64(math.Float64bits(value))
+	return nil
+}
+
+func (e *Encoder) WriteBytes(name string, value []byte) error {
+	err := e.writeHead(tagBytes, name)
 	if err != nil {
 		return err
 	}
-	_, err = e.writer.Write(bytes)
-	return err
+	e.writeLength(len(value))
+	e.buffer = append(e.buffer, value...)
+	return nil
 }
 
-func (e *Encoder) writeArray(name string, value reflect.Value) error {
-	err := e.writeTag(tagArrayStart)
+func (e *Encoder) WriteString(name string, value string) error {
+	err := e.writeHead(tagString, name)
 	if err != nil {
 		return err
 	}
-	err = e.writeName(name)
+	e.writeLength(len(value))
+	e.buffer = append(e.buffer, value...)
+	return nil
+}
+
+func (e *Encoder) WriteTime(name string, value *time.Time) error {
+	err := e.writeHead(tagTimestamp, name)
 	if err != nil {
 		return err
 	}
+	e.writeSigned(value.UnixMilli(), 8)
+	return nil
+}
 
-	count := value.Len()
-	for i := 0; i < count; i++ {
-		err = e.write("", value.Index(i))
-		if err != nil {
-			return err
-		}
+// WriteTimeNs writes value with nanoseconds and its UTC offset, a time in UTC is written without offset.
+func (e *Encoder) WriteTimeNs(name string, value *time.Time) error {
+	err := e.writeHead(tagTimestampNs, name)
+	if err != nil {
+		return err
+	}
+	zoned := value.Location() != time.UTC
+	nanos := uint64(value.Nanosecond()) << 1
+	if zoned {
+		nanos |= 1
 	}
+	e.writeSigned(value.Unix(), 8)
+	e.writeUnsigned(nanos, 4)
+	if zoned {
+		_, offset := value.Zone()
+		e.writeSigned(int64(offset), 4)
+	}
+	return nil
+}
 
-	err = e.writeTag(tagArrayEnd)
-	return err
+func (e *Encoder) WriteDuration(name string, value time.Duration) error {
+	err := e.writeHead(tagDuration, name)
+	if err != nil {
+		return err
+	}
+	e.writeSigned(int64(value), 8)
+	return nil
 }
 
-func (e *Encoder) writeMap(name string, value reflect.Value) error {
-	err := e.writeTag(tagObjectStart)
+func (e *Encoder) WriteEnum(name string, value Enum) error {
+	enum, err := value.GetValue()
 	if err != nil {
 		return err
 	}
-	err = e.writeName(name)
+	err = e.writeHead(tagEnum, name)
 	if err != nil {
 		return err
 	}
+	e.buffer = append(e.buffer, byte(len(enum)))
+	e.buffer = append(e.buffer, enum...)
+	return nil
+}
 
-	keys := value.MapKeys()
-	for i, key := range keys {
-		if i == 0 && key.Kind() != reflect.String {
-			return fmt.Errorf("map key type must be string")
-		}
-		if isNull(value.MapIndex(key)) {
-			continue
-		}
-		err = e.write(key.String(), value.MapIndex(key))
-		if err != nil {
-			return err
-		}
+// WriteNull writes a null value.
+func (e *Encoder) WriteNull(name string) error {
+	return e.writeHead(tagNull, name)
+}
+
+// WriteNullField writes null for a nil struct field or map value if EncoderOptions.NullFields is set.
+func (e *Encoder) WriteNullField(name string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if !e.options.NullFields {
+		return nil
 	}
+	return e.WriteNull(name)
+}
 
-	return e.writeTag(tagObjectEnd)
+// WriteArray writes an array, fn writes the elements with empty names.
+func (e *Encoder) WriteArray(name string, fn func() error) error {
+	err := e.BeginArray(name)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if err != nil {
+		e.pop()
+		return err
+	}
+	return e.EndArray()
 }
 
-func (e *Encoder) writeObject(name string, value reflect.Value) error {
-	err := e.writeTag(tagObjectStart)
+// WriteObject writes an object, fn writes the fields with their keys as names.
+func (e *Encoder) WriteObject(name string, fn func() error) error {
+	err := e.BeginObject(name)
 	if err != nil {
 		return err
 	}
-	err = e.writeName(name)
+	err = fn()
 	if err != nil {
+		e.pop()
 		return err
 	}
+	return e.EndObject()
+}
 
-	info, err := getStructInfo(value.Type())
+// BeginArray starts an array of unknown length, the elements are written with empty names
+// until EndArray. The buffered data may be flushed in between to stream large arrays.
+func (e *Encoder) BeginArray(name string) error {
+	return e.begin(tagArrayStart, name)
+}
+
+// EndArray ends the array started by the last BeginArray.
+func (e *Encoder) EndArray() error {
+	return e.end(tagArrayStart, tagArrayEnd, "EndArray")
+}
+
+// BeginObject starts an object of unknown length, the fields are written with WriteField
+// or with their keys as names until EndObject.
+func (e *Encoder) BeginObject(name string) error {
+	return e.begin(tagObjectStart, name)
+}
+
+// EndObject ends the object started by the last BeginObject.
+func (e *Encoder) EndObject() error {
+	return e.end(tagObjectStart, tagObjectEnd, "EndObject")
+}
+
+// WriteField writes a field of the current object, nil values follow EncoderOptions.NullFields.
+func (e *Encoder) WriteField(key string, value interface{}) error {
+	if e.container() != tagObjectStart {
+		return fmt.Errorf("WriteField called outside an object")
+	}
+	v := reflect.ValueOf(value)
+	if isNull(v) {
+		return e.WriteNullField(key)
+	}
+	return e.write(key, v)
+}
+
+func (e *Encoder) begin(t tag, name string) error {
+	err := e.writeHead(t, name)
 	if err != nil {
 		return err
 	}
-	for _, field := range info.fieldsList {
-		fieldValue := value.Field(field.index)
-		if isNull(fieldValue) {
-			continue
+	e.containers = append(e.containers, t)
+	return nil
+}
+
+func (e *Encoder) end(start, end tag, method string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if len(e.containers) == 0 {
+		return fmt.Errorf("%s called outside any array or object", method)
+	}
+	if e.container() != start {
+		return fmt.Errorf("%s called inside an %s", method, e.container())
+	}
+	e.containers = e.containers[:len(e.containers)-1]
+	return e.writeTag(end)
+}
+
+// pop leaves the innermost array or object without ending it, after its content failed.
+func (e *Encoder) pop() {
+	if len(e.containers) > 0 {
+		e.containers = e.containers[:len(e.containers)-1]
+	}
+}
+
+// container returns the start tag of the innermost array or object, tagUndefined at top level.
+func (e *Encoder) container() tag {
+	if len(e.containers) == 0 {
+		return tagUndefined
+	}
+	return e.containers[len(e.containers)-1]
+}
+
+func (e *Encoder) write(name string, value reflect.Value) error {
+	if isNull(value) {
+		return nil
+	}
+	if encode := e.encodeFunc(value.Type()); encode != nil {
+		return encode(e, name, value.Interface())
+	}
+	if m, ok := marshaler(value); ok {
+		return m.MarshalDisorder(e, name)
+	}
+
+	if value.Kind() != reflect.Ptr && reflect.PtrTo(value.Type()).Implements(enumType) {
+		// Enums stored by value are written like pointers to them, addressable or not.
+		value = addressable(value)
+	}
+	switch i := value.Interface().(type) {
+	case *time.Time:
+		return e.writeTime(name, i)
+
+	case time.Time:
+		return e.writeTime(name, &i)
+
+	case time.Duration:
+		return e.WriteDuration(name, i)
+
+	case RawMessage:
+		return e.WriteRaw(name, i)
+
+	case Enum:
+		return e.WriteEnum(name, i)
+	}
+	if m, ok := textMarshaler(value); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return err
 		}
-		err = e.write(field.key, fieldValue)
+		return e.WriteString(name, string(text))
+	}
+	if m, ok := binaryMarshaler(value); ok {
+		data, err := m.MarshalBinary()
 		if err != nil {
 			return err
 		}
+		return e.WriteBytes(name, data)
+	}
+
+	switch value.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return e.write(name, value.Elem())
+
+	case reflect.Bool:
+		return e.WriteBool(name, value.Bool())
+
+	case reflect.Int8, reflect.Int16:
+		return e.WriteShort(name, int16(value.Int()))
+
+	case reflect.Uint8, reflect.Uint16:
+		return e.WriteUshort(name, uint16(value.Uint()))
+
+	case reflect.Int32:
+		return e.WriteInt(name, int32(value.Int()))
+
+	case reflect.Uint32:
+		return e.WriteUint(name, uint32(value.Uint()))
+
+	case reflect.Int, reflect.Int64:
+		return e.WriteLong(name, value.Int())
+
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return e.WriteUlong(name, value.Uint())
+
+	case reflect.Float32:
+		return e.WriteFloat(name, float32(value.Float()))
+
+	case reflect.Float64:
+		return e.WriteDouble(name, value.Float())
+
+	case reflect.String:
+		return e.WriteString(name, value.String())
+
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return e.WriteBytes(name, value.Bytes())
+		}
+		return e.writeArray(name, value)
+
+	case reflect.Array:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			bytes := make([]byte, value.Len())
+			reflect.Copy(reflect.ValueOf(bytes), value)
+			return e.WriteBytes(name, bytes)
+		}
+		return e.writeArray(name, value)
+
+	case reflect.Map:
+		return e.writeMap(name, value)
+
+	case reflect.Struct:
+		return e.writeObject(name, value)
+	}
+	return fmt.Errorf("unsupported type: %s", value.Type().String())
+}
+
+func (e *Encoder) writeTime(name string, value *time.Time) error {
+	if e.options.Time == TimeNanos {
+		return e.WriteTimeNs(name, value)
+	}
+	return e.WriteTime(name, value)
+}
+
+func (e *Encoder) writeArray(name string, value reflect.Value) error {
+	return e.WriteArray(name, func() error {
+		count := value.Len()
+		for i := 0; i < count; i++ {
+			element := value.Index(i)
+			var err error
+			if isNull(element) {
+				err = e.WriteNull("")
+			} else {
+				err = e.write("", element)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (e *Encoder) writeMap(name string, value reflect.Value) error {
+	if !validKey(value.Type().Key()) {
+		return fmt.Errorf("unsupported map key type %s", value.Type().Key())
+	}
+	return e.WriteObject(name, func() error {
+		keys := value.MapKeys()
+		names := make([]string, len(keys))
+		for i, key := range keys {
+			var err error
+			names[i], err = marshalKey(key)
+			if err != nil {
+				return err
+			}
+		}
+		if e.options.Canonical {
+			sort.Sort(&mapEntries{names: names, keys: keys})
+		}
+		for i, key := range keys {
+			if isNull(value.MapIndex(key)) {
+				err := e.WriteNullField(names[i])
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			err := e.write(names[i], value.MapIndex(key))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// mapEntries sorts map keys by their encoded names.
+type mapEntries struct {
+	names []string
+	keys  []reflect.Value
+}
+
+func (m *mapEntries) Len() int {
+	return len(m.names)
+}
+
+func (m *mapEntries) Less(i, j int) bool {
+	return m.names[i] < m.names[j]
+}
+
+func (m *mapEntries) Swap(i, j int) {
+	m.names[i], m.names[j] = m.names[j], m.names[i]
+	m.keys[i], m.keys[j] = m.keys[j], m.keys[i]
+}
+
+func (e *Encoder) writeObject(name string, value reflect.Value) error {
+	info, err := getStructInfo(value.Type())
+	if err != nil {
+		return err
+	}
+	return e.WriteObject(name, func() error {
+		for _, field := range info.fieldsList {
+			fieldValue := fieldByIndex(value, field.index, false)
+			if !fieldValue.IsValid() {
+				continue // Promoted from a nil embedded pointer
+			}
+			if field.omitEmpty && (isNull(fieldValue) || isEmpty(fieldValue)) {
+				continue
+			}
+			var err error
+			switch {
+			case isNull(fieldValue) && field.required:
+				err = e.WriteNull(field.key)
+			case isNull(fieldValue):
+				err = e.WriteNullField(field.key)
+			case field.quoted:
+				err = e.writeQuoted(field.key, fieldValue)
+			default:
+				err = e.write(field.key, fieldValue)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		if info.unknown != nil {
+			return e.WriteUnknown(value.FieldByIndex(info.unknown).Bytes())
+		}
+		return nil
+	})
+}
+
+// writeQuoted writes a bool or number as string, for fields with the string option.
+func (e *Encoder) writeQuoted(name string, value reflect.Value) error {
+	value = reflect.Indirect(value)
+	var s string
+	switch value.Kind() {
+	case reflect.Bool:
+		s = strconv.FormatBool(value.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s = strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s = strconv.FormatUint(value.Uint(), 10)
+	default:
+		s = strconv.FormatFloat(value.Float(), 'g', -1, value.Type().Bits())
+	}
+	return e.WriteString(name, s)
+}
+
+// writeHead writes the tag and the name of a value.
+func (e *Encoder) writeHead(t tag, name string) error {
+	if e.options.Compact && compactable(t) {
+		t |= tagCompact
+	}
+	return e.writeKeyHead(t, name)
+}
+
+// writeKeyHead writes the tag as is and the name of a value. Object fields must have a name,
+// array elements and top level values must not.
+func (e *Encoder) writeKeyHead(t tag, name string) error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.container() == tagObjectStart && name == "" {
+		return fmt.Errorf("object field without key")
+	}
+	if e.container() != tagObjectStart && name != "" {
+		return fmt.Errorf("value \"%s\" written outside an object", name)
+	}
+	if e.options.InternKeys && len(name) > 0 {
+		if index, ok := e.keys[name]; ok {
+			err := e.writeTag(tagKeyRef)
+			if err != nil {
+				return err
+			}
+			e.writeVarint(uint64(index))
+			return e.writeTag(t)
+		}
+		e.addKey(name)
 	}
+	err := e.writeTag(t)
+	if err != nil {
+		return err
+	}
+	return e.writeName(name)
+}
+
+// addKey adds a key written in full to the key table.
+func (e *Encoder) addKey(name string) {
+	if e.keyCount >= keyTableSize {
+		return
+	}
+	if e.keys == nil {
+		e.keys = make(map[string]int)
+	}
+	if _, ok := e.keys[name]; !ok {
+		e.keys[name] = e.keyCount
+	}
+	e.keyCount++
+}
+
+// writeSigned writes the low size bytes of value, or its zigzag varint in compact mode.
+func (e *Encoder) writeSigned(value int64, size int) {
+	if e.options.Compact {
+		e.writeVarint(zigzag(value))
+		return
+	}
+	e.writeUnsigned(uint64(value), size)
+}
+
+// writeUnsigned writes the low size bytes of value, or its varint in compact mode.
+func (e *Encoder) writeUnsigned(value uint64, size int) {
+	if e.options.Compact {
+		e.writeVarint(value)
+		return
+	}
+	switch size {
+	case 2:
+		e.writeUint16(uint16(value))
+	case 4:
+		e.writeUint32(uint32(value))
+	default:
+		e.writeUint64(value)
+	}
+}
 
-	return e.writeTag(tagObjectEnd)
+func (e *Encoder) writeLength(length int) {
+	e.writeUnsigned(uint64(length), 4)
+}
+
+func (e *Encoder) writeVarint(value uint64) {
+	n := binary.PutUvarint(e.scratch[:], value)
+	e.buffer = append(e.buffer, e.scratch[:n]...)
+}
+
+func (e *Encoder) writeUint16(value uint16) {
+	binary.BigEndian.PutUint16(e.scratch[:2], value)
+	e.buffer = append(e.buffer, e.scratch[:2]...)
+}
+
+func (e *Encoder) writeUint32(value uint32) {
+	binary.BigEndian.PutUint32(e.scratch[:4], value)
+	e.buffer = append(e.buffer, e.scratch[:4]...)
+}
+
+func (e *Encoder) writeUint64(value uint64) {
+	binary.BigEndian.PutUint64(e.scratch[:8], value)
+	e.buffer = append(e.buffer, e.scratch[:8]...)
 }
 
 func (e *Encoder) writeName(value string) error {
@@ -216,15 +762,19 @@ func (e *Encoder) writeName(value string) error {
 	if len(value) > 255 {
 		return fmt.Errorf("string length overflow. should less than 255")
 	}
-	_, err := e.writer.Write([]byte{byte(len(value))})
-	if err != nil {
-		return err
-	}
-	_, err = e.writer.Write([]byte(value))
-	return err
+	e.buffer = append(e.buffer, byte(len(value)))
+	e.buffer = append(e.buffer, value...)
+	return nil
 }
 
+// writeTag starts every value, so it is where the buffer gets flushed when full.
 func (e *Encoder) writeTag(t tag) error {
-	_, err := e.writer.Write([]byte{byte(t)})
-	return err
+	if len(e.buffer) >= bufferSize {
+		err := e.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	e.buffer = append(e.buffer, byte(t))
+	return nil
 }
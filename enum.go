@@ -1,7 +1,36 @@
 package disorder
 
+import "fmt"
+
 type Enum interface {
 	Enum()
 	GetValue() (string, error)
 	SetValue(enum string) error
 }
+
+// EnumValue holds an enum decoded into an interface{} target,
+// where the concrete enum type is unknown.
+type EnumValue string
+
+func (*EnumValue) Enum() {}
+
+func (enum *EnumValue) GetValue() (string, error) {
+	if len(*enum) == 0 {
+		return "", fmt.Errorf("empty enum value")
+	}
+	if len(*enum) > 255 {
+		return "", fmt.Errorf("enum length overflow. should less than 255")
+	}
+	return string(*enum), nil
+}
+
+func (enum *EnumValue) SetValue(value string) error {
+	if value == "" {
+		return fmt.Errorf("empty enum value")
+	}
+	if len(value) > 255 {
+		return fmt.Errorf("enum length overflow. should less than 255")
+	}
+	*enum = EnumValue(value)
+	return nil
+}
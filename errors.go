@@ -0,0 +1,83 @@
+package disorder
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// ErrUnexpectedEOF means the stream ended inside a value.
+// A stream ending cleanly between top level values is reported as io.EOF instead.
+var ErrUnexpectedEOF = errors.New("unexpected EOF")
+
+type UnexpectedEOFError struct {
+	Offset int64
+}
+
+func (e *UnexpectedEOFError) Error() string {
+	return fmt.Sprintf("unexpected EOF at offset %d", e.Offset)
+}
+
+func (e *UnexpectedEOFError) Is(target error) bool {
+	return target == ErrUnexpectedEOF
+}
+
+// ErrLimitExceeded means the data exceeds one of the DecoderOptions limits.
+var ErrLimitExceeded = errors.New("decoder limit exceeded")
+
+type LimitError struct {
+	Limit  string
+	Max    int64
+	Offset int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("decoder limit %s (%d) exceeded at offset %d", e.Limit, e.Max, e.Offset)
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// ErrUnknownField means an object field has no matching struct field.
+var ErrUnknownField = errors.New("unknown field")
+
+// UnknownFieldError is returned by a decoder with DisallowUnknownFields,
+// otherwise it is collected in Decoder.Warnings and the field is skipped.
+type UnknownFieldError struct {
+	Type   reflect.Type
+	Key    string
+	Tag    string
+	Offset int64
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("field %s not found in struct %s (%s at offset %d)", e.Key, e.Type, e.Tag, e.Offset)
+}
+
+func (e *UnknownFieldError) Is(target error) bool {
+	return target == ErrUnknownField
+}
+
+// DecodeError locates a value which failed to decode. Path starts from the decoded struct type,
+// struct fields follow a dot and map keys and array indexes are in brackets, like
+// Object.nested["key0"][0]. Type is the expected Go type when known, Tag the wire tag found
+// and Offset where the value starts.
+type DecodeError struct {
+	Path   string
+	Type   string
+	Tag    string
+	Offset int64
+	Err    error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("decode failed at offset %d: %v", e.Offset, e.Err)
+	}
+	return fmt.Sprintf("decode %s failed at offset %d: %v", e.Path, e.Offset, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,129 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/meerkat-io/disorder"
+	"github.com/meerkat-io/disorder/internal/test_data/test"
+	"github.com/meerkat-io/disorder/internal/test_data/test/sub"
+)
+
+// reflect* types mirror the generated types without the MarshalDisorder/UnmarshalDisorder
+// methods, so they take the reflective path.
+type reflectNumber struct {
+	Value int32 `disorder:"value"`
+}
+
+type reflectNumberWrapper struct {
+	Value *reflectNumber `disorder:"value"`
+}
+
+type reflectObject struct {
+	IntField    int32                            `disorder:"int_field"`
+	StringField string                           `disorder:"string_field"`
+	BytesFields []byte                           `disorder:"bytes_fields"`
+	EnumField   *test.Color                      `disorder:"enum_field"`
+	TimeField   *time.Time                       `disorder:"time_field"`
+	IntArray    []int32                          `disorder:"int_array"`
+	IntMap      map[string]int32                 `disorder:"int_map"`
+	ObjArray    []*reflectNumberWrapper          `disorder:"obj_array"`
+	ObjMap      map[string]*reflectNumberWrapper `disorder:"obj_map"`
+}
+
+func newBenchmarkObject() *test.Object {
+	timestamp := time.UnixMilli(time.Now().UnixMilli())
+	color := test.ColorBlue
+	return &test.Object{
+		IntField:    123,
+		StringField: "foo",
+		BytesFields: []byte{7, 8, 9},
+		EnumField:   &color,
+		TimeField:   &timestamp,
+		IntArray:    []int32{1, 2, 3, 4, 5, 6, 7, 8},
+		IntMap: map[string]int32{
+			"4": 4,
+			"5": 5,
+			"6": 6,
+		},
+		ObjArray: []*sub.NumberWrapper{
+			{Value: &sub.Number{Value: 1}},
+			{Value: &sub.Number{Value: 2}},
+			{Value: &sub.Number{Value: 3}},
+		},
+		ObjMap: map[string]*sub.NumberWrapper{
+			"789": {Value: &sub.Number{Value: 789}},
+		},
+	}
+}
+
+func newReflectObject() *reflectObject {
+	object := newBenchmarkObject()
+	return &reflectObject{
+		IntField:    object.IntField,
+		StringField: object.StringField,
+		BytesFields: object.BytesFields,
+		EnumField:   object.EnumField,
+		TimeField:   object.TimeField,
+		IntArray:    object.IntArray,
+		IntMap:      object.IntMap,
+		ObjArray: []*reflectNumberWrapper{
+			{Value: &reflectNumber{Value: 1}},
+			{Value: &reflectNumber{Value: 2}},
+			{Value: &reflectNumber{Value: 3}},
+		},
+		ObjMap: map[string]*reflectNumberWrapper{
+			"789": {Value: &reflectNumber{Value: 789}},
+		},
+	}
+}
+
+func BenchmarkMarshalReflect(b *testing.B) {
+	object := newReflectObject()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := disorder.Marshal(object); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMarshalGenerated(b *testing.B) {
+	object := newBenchmarkObject()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := disorder.Marshal(object); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalReflect(b *testing.B) {
+	data, err := disorder.Marshal(newReflectObject())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var object reflectObject
+		if err := disorder.Unmarshal(data, &object); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalGenerated(b *testing.B) {
+	data, err := disorder.Marshal(newBenchmarkObject())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var object test.Object
+		if err := disorder.Unmarshal(data, &object); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
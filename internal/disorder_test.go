@@ -1,8 +1,11 @@
 package internal_test
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"testing"
 	"time"
 
@@ -100,10 +103,18 @@ func TestObjectType(t *testing.T) {
 	assert.Nil(t, err)
 
 	assert.Equal(t, object0, object3)
+	assert.True(t, object3.HasValue())
 	assert.Equal(t, object1, object2)
 	assert.JSONEq(t, string(json0), string(json1))
 	assert.JSONEq(t, string(json0), string(json2))
 	assert.JSONEq(t, string(json0), string(json3))
+
+	// Presence is reset by each decode
+	empty, err := disorder.Marshal(map[string]int32{})
+	assert.Nil(t, err)
+	err = disorder.Unmarshal(empty, &object3)
+	assert.Nil(t, err)
+	assert.False(t, object3.HasValue())
 }
 
 func TestAllTypes(t *testing.T) {
@@ -187,6 +198,273 @@ func TestAllTypes(t *testing.T) {
 	assert.Equal(t, object0.IntField, miniObject.IntField)
 }
 
+func TestAllTypesEncodings(t *testing.T) {
+	timestamp := time.Unix(time.Now().Unix(), 0)
+	timestampNs := time.Unix(time.Now().Unix(), 123456789).In(time.FixedZone("", 8*3600))
+	color := test.ColorBlue
+	object0 := test.Object{
+		IntField:      123,
+		UintField:     math.MaxUint32,
+		UlongField:    math.MaxUint64,
+		ShortField:    math.MinInt16,
+		UshortField:   math.MaxUint16,
+		StringField:   "foo",
+		BytesFields:   []byte{7, 8, 9},
+		EnumField:     &color,
+		TimeField:     &timestamp,
+		TimeNsField:   &timestampNs,
+		DurationField: 1500 * time.Microsecond,
+		ObjField: &sub.NumberWrapper{
+			Value: &sub.Number{
+				Value: 789,
+			},
+		},
+		IntArray: []int32{1, 2, 3},
+		IntMap: map[string]int32{
+			"4": 4,
+			"5": 5,
+			"6": 6,
+		},
+		ObjArray: []*sub.NumberWrapper{{Value: &sub.Number{
+			Value: 789,
+		}}},
+		ObjMap: map[string]*sub.NumberWrapper{
+			"789": {Value: &sub.Number{
+				Value: 789,
+			}},
+		},
+		Nested: map[string]map[string][][]map[string]*test.Color{
+			"key0": {
+				"key1": {
+					{
+						{
+							"key2": &color,
+						},
+					},
+				},
+			},
+		},
+	}
+	for _, options := range []disorder.EncoderOptions{{Time: disorder.TimeNanos}, {Compact: true, Time: disorder.TimeNanos}} {
+		data0, err := disorder.Marshal(&object0, options)
+		assert.Nil(t, err)
+		var object1 interface{}
+		err = disorder.Unmarshal(data0, &object1)
+		assert.Nil(t, err)
+		data1, err := disorder.Marshal(&object1, options)
+		assert.Nil(t, err)
+		object2 := test.Object{}
+		err = disorder.Unmarshal(data1, &object2)
+		assert.Nil(t, err)
+		assert.Equal(t, object0, object2)
+	}
+
+	plain, err := disorder.Marshal(&object0, disorder.EncoderOptions{Time: disorder.TimeNanos})
+	assert.Nil(t, err)
+	compact, err := disorder.Marshal(&object0, disorder.EncoderOptions{Compact: true, Time: disorder.TimeNanos})
+	assert.Nil(t, err)
+	assert.Less(t, len(compact), len(plain))
+}
+
+func TestNullFields(t *testing.T) {
+	object0 := test.Object{
+		ObjArray: []*sub.NumberWrapper{{Value: &sub.Number{Value: 1}}, nil, {}},
+		ObjMap:   map[string]*sub.NumberWrapper{"nil": nil},
+	}
+	data, err := disorder.Marshal(&object0)
+	assert.Nil(t, err)
+	object1 := test.Object{ObjField: &sub.NumberWrapper{}}
+	err = disorder.Unmarshal(data, &object1)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(object1.ObjArray))
+	assert.Equal(t, int32(1), object1.ObjArray[0].Value.Value)
+	assert.Nil(t, object1.ObjArray[1])
+	assert.Equal(t, map[string]*sub.NumberWrapper{}, object1.ObjMap)
+	assert.NotNil(t, object1.ObjField)
+	assert.False(t, object1.ObjArray[2].HasValue())
+
+	data, err = disorder.Marshal(&object0, disorder.EncoderOptions{NullFields: true})
+	assert.Nil(t, err)
+	object1 = test.Object{ObjField: &sub.NumberWrapper{}}
+	err = disorder.Unmarshal(data, &object1)
+	assert.Nil(t, err)
+	assert.Equal(t, object0.ObjMap, object1.ObjMap)
+	assert.Nil(t, object1.ObjField)
+	assert.True(t, object1.ObjArray[2].HasValue())
+
+	var object2 interface{}
+	err = disorder.Unmarshal(data, &object2)
+	assert.Nil(t, err)
+	assert.Nil(t, object2.(map[string]interface{})["obj_field"])
+	assert.Nil(t, object2.(map[string]interface{})["obj_array"].([]interface{})[1])
+}
+
+func TestUnknownFields(t *testing.T) {
+	fields0 := map[string]interface{}{
+		"value":  int32(1),
+		"extra":  "foo",
+		"nested": map[string]interface{}{"list": []interface{}{int64(2), "bar"}},
+	}
+	data, err := disorder.Marshal(fields0, disorder.EncoderOptions{InternKeys: true})
+	assert.Nil(t, err)
+	var number sub.Number
+	err = disorder.Unmarshal(data, &number)
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), number.Value)
+
+	data, err = disorder.Marshal(&number)
+	assert.Nil(t, err)
+	var fields1 map[string]interface{}
+	err = disorder.Unmarshal(data, &fields1)
+	assert.Nil(t, err)
+	assert.Equal(t, fields0, fields1)
+
+	data, err = disorder.Marshal(map[string]interface{}{"value": int32(1), "extra": "x"})
+	assert.Nil(t, err)
+	decoder := disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{DisallowUnknownFields: true})
+	err = decoder.Decode(&number)
+	assert.True(t, errors.Is(err, disorder.ErrUnknownField))
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data))
+	err = decoder.Decode(&number)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(decoder.Warnings()))
+
+	// Messages of schemas without go_unknown_fields skip unknown fields with a warning
+	var zero test.Zero
+	decoder = disorder.NewDecoder(bytes.NewBuffer(data))
+	err = decoder.Decode(&zero)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(decoder.Warnings()))
+}
+
+func TestDecodeError(t *testing.T) {
+	data, err := disorder.Marshal(map[string]interface{}{
+		"obj_map": map[string]interface{}{"789": map[string]interface{}{"value": map[string]interface{}{"value": "foo"}}},
+	})
+	assert.Nil(t, err)
+	var object test.Object
+	err = disorder.Unmarshal(data, &object)
+	var decodeErr *disorder.DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, `Object.obj_map["789"].value.value`, decodeErr.Path)
+	assert.Equal(t, "int32", decodeErr.Type)
+	assert.Equal(t, "string", decodeErr.Tag)
+}
+
+func TestDecodeModes(t *testing.T) {
+	data, err := disorder.Marshal(map[string]interface{}{
+		"int_array": []int32{4, 5},
+		"int_map":   map[string]int32{"b": 2},
+	})
+	assert.Nil(t, err)
+	for _, c := range []struct {
+		mode   disorder.DecodeMode
+		str    string
+		array  []int32
+		values map[string]int32
+	}{
+		{disorder.DecodeMerge, "foo", []int32{4, 5}, map[string]int32{"a": 1, "b": 2}},
+		{disorder.DecodeReplace, "", []int32{4, 5}, map[string]int32{"b": 2}},
+		{disorder.DecodeAppend, "foo", []int32{1, 2, 3, 4, 5}, map[string]int32{"a": 1, "b": 2}},
+	} {
+		object := test.Object{
+			StringField: "foo",
+			IntArray:    []int32{1, 2, 3},
+			IntMap:      map[string]int32{"a": 1},
+		}
+		d := disorder.NewDecoder(bytes.NewBuffer(data), disorder.DecoderOptions{Mode: c.mode})
+		err = d.Decode(&object)
+		assert.Nil(t, err)
+		assert.Equal(t, c.str, object.StringField)
+		assert.Equal(t, c.array, object.IntArray)
+		assert.Equal(t, c.values, object.IntMap)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	object := test.Object{IntMap: map[string]int32{}}
+	for i := 0; i < 32; i++ {
+		object.IntMap[fmt.Sprint(i)] = int32(i)
+	}
+	data0, err := disorder.Canonical(&object)
+	assert.Nil(t, err)
+	for i := 0; i < 16; i++ {
+		data1, err := disorder.Canonical(&object)
+		assert.Nil(t, err)
+		assert.Equal(t, data0, data1)
+	}
+	var result test.Object
+	err = disorder.Unmarshal(data0, &result)
+	assert.Nil(t, err)
+	assert.Equal(t, object, result)
+}
+
+func TestMapKeys(t *testing.T) {
+	object0 := test.Object{
+		LongMap: map[int64]string{-1: "foo", 1 << 40: "bar"},
+		EnumMap: map[test.Color]map[uint16]*sub.NumberWrapper{
+			test.ColorRed:  {1: {Value: &sub.Number{Value: 1}}, 65535: nil},
+			test.ColorBlue: {},
+		},
+	}
+	data, err := disorder.Canonical(&object0)
+	assert.Nil(t, err)
+	var object1 test.Object
+	err = disorder.Unmarshal(data, &object1)
+	assert.Nil(t, err)
+	assert.Equal(t, object0.LongMap, object1.LongMap)
+	assert.Equal(t, 2, len(object1.EnumMap))
+	assert.Equal(t, int32(1), object1.EnumMap[test.ColorRed][1].Value.Value)
+	assert.Nil(t, object1.EnumMap[test.ColorRed][65535])
+	assert.NotNil(t, object1.EnumMap[test.ColorBlue])
+
+	var fields map[string]interface{}
+	err = disorder.Unmarshal(data, &fields)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"-1": "foo", "1099511627776": "bar"}, fields["long_map"])
+
+	object0.EnumMap = map[test.Color]map[uint16]*sub.NumberWrapper{"purple": nil}
+	_, err = disorder.Marshal(&object0)
+	assert.NotNil(t, err)
+}
+
+func TestValueEnums(t *testing.T) {
+	colors0 := map[string]test.Color{"k": test.ColorRed}
+	data, err := disorder.Marshal(colors0)
+	assert.Nil(t, err)
+	var colors1 map[string]test.Color
+	err = disorder.Unmarshal(data, &colors1)
+	assert.Nil(t, err)
+	assert.Equal(t, colors0, colors1)
+
+	// Values passed by value or by pointer encode the same
+	holder0 := ColorHolder{Color: test.ColorGreen, Colors: [2]test.Color{test.ColorRed, test.ColorBlue}}
+	byValue, err := disorder.Marshal(holder0)
+	assert.Nil(t, err)
+	byPointer, err := disorder.Marshal(&holder0)
+	assert.Nil(t, err)
+	assert.Equal(t, byPointer, byValue)
+	var holder1 ColorHolder
+	err = disorder.Unmarshal(byValue, &holder1)
+	assert.Nil(t, err)
+	assert.Equal(t, holder0, holder1)
+
+	data, err = disorder.Marshal(test.ColorBlue)
+	assert.Nil(t, err)
+	var color test.Color
+	err = disorder.Unmarshal(data, &color)
+	assert.Nil(t, err)
+	assert.Equal(t, test.ColorBlue, color)
+
+	// Enums written as strings still decode
+	data, err = disorder.Marshal(map[string]interface{}{"color": "red", "colors": []string{"green", "blue"}})
+	assert.Nil(t, err)
+	var holder2 ColorHolder
+	err = disorder.Unmarshal(data, &holder2)
+	assert.Nil(t, err)
+	assert.Equal(t, ColorHolder{Color: test.ColorRed, Colors: [2]test.Color{test.ColorGreen, test.ColorBlue}}, holder2)
+}
+
 func TestZero(t *testing.T) {
 	object0 := test.Zero{
 		ZeroArray: []int32{},
@@ -392,6 +670,11 @@ func (*testService) PrintNested(c *rpc.Context, request map[string]map[string][]
 }
 */
 
+type ColorHolder struct {
+	Color  test.Color    `disorder:"color"`
+	Colors [2]test.Color `disorder:"colors"`
+}
+
 type MiniObject struct {
 	IntField int32 `disorder:"int_field" json:"int_field"`
 }
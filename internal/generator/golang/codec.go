@@ -0,0 +1,194 @@
+package golang
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/meerkat-io/disorder/internal/schema"
+)
+
+var goWriters = map[schema.Type]string{
+	schema.TypeBool:     "WriteBool",
+	schema.TypeInt:      "WriteInt",
+	schema.TypeLong:     "WriteLong",
+	schema.TypeUint:     "WriteUint",
+	schema.TypeUlong:    "WriteUlong",
+	schema.TypeShort:    "WriteShort",
+	schema.TypeUshort:   "WriteUshort",
+	schema.TypeFloat:    "WriteFloat",
+	schema.TypeDouble:   "WriteDouble",
+	schema.TypeString:   "WriteString",
+	schema.TypeDuration: "WriteDuration",
+}
+
+var goReaders = map[schema.Type]string{
+	schema.TypeBool:     "ReadBool",
+	schema.TypeInt:      "ReadInt",
+	schema.TypeLong:     "ReadLong",
+	schema.TypeUint:     "ReadUint",
+	schema.TypeUlong:    "ReadUlong",
+	schema.TypeShort:    "ReadShort",
+	schema.TypeUshort:   "ReadUshort",
+	schema.TypeFloat:    "ReadFloat",
+	schema.TypeDouble:   "ReadDouble",
+	schema.TypeString:   "ReadString",
+	schema.TypeDuration: "ReadDuration",
+}
+
+// encodeValue generates statements writing value with name through encoder "e",
+// errors are returned from the enclosing function.
+func encodeValue(typ *schema.TypeInfo, value, name string, depth int) string {
+	b := &strings.Builder{}
+	switch typ.Type {
+	case schema.TypeBytes:
+		fmt.Fprintf(b, "if %s != nil {\n", value)
+		fmt.Fprintf(b, "if err := e.WriteBytes(%s, %s); err != nil {\nreturn err\n}\n", name, value)
+		b.WriteString(encodeNull(name))
+
+	case schema.TypeTimestamp, schema.TypeTimestampNs:
+		writer := "WriteTime"
+		if typ.Type == schema.TypeTimestampNs {
+			writer = "WriteTimeNs"
+		}
+		fmt.Fprintf(b, "if %s != nil {\n", value)
+		fmt.Fprintf(b, "if err := e.%s(%s, %s); err != nil {\nreturn err\n}\n", writer, name, value)
+		b.WriteString(encodeNull(name))
+
+	case schema.TypeEnum, schema.TypeObject:
+		fmt.Fprintf(b, "if %s != nil {\n", value)
+		fmt.Fprintf(b, "if err := %s.MarshalDisorder(e, %s); err != nil {\nreturn err\n}\n", value, name)
+		b.WriteString(encodeNull(name))
+
+	case schema.TypeArray:
+		element := fmt.Sprintf("v%d", depth)
+		fmt.Fprintf(b, "if %s != nil {\n", value)
+		fmt.Fprintf(b, "if err := e.WriteArray(%s, func() error {\n", name)
+		fmt.Fprintf(b, "for _, %s := range %s {\n", element, value)
+		b.WriteString(encodeValue(typ.ElementType, element, `""`, depth+1))
+		b.WriteString("}\nreturn nil\n}); err != nil {\nreturn err\n}\n")
+		b.WriteString(encodeNull(name))
+
+	case schema.TypeMap:
+		key := fmt.Sprintf("k%d", depth)
+		element := fmt.Sprintf("v%d", depth)
+		fmt.Fprintf(b, "if %s != nil {\n", value)
+		keys := fmt.Sprintf("keys%d", depth)
+		fmt.Fprintf(b, "if err := e.WriteObject(%s, func() error {\n", name)
+		fmt.Fprintf(b, "%s := make([]string, 0, len(%s))\n", keys, value)
+		if typ.KeyType == nil {
+			fmt.Fprintf(b, "for %s := range %s {\n%s = append(%s, %s)\n}\n", key, value, keys, keys, key)
+			fmt.Fprintf(b, "e.SortKeys(%s)\n", keys)
+			fmt.Fprintf(b, "for _, %s := range %s {\n%s := %s[%s]\n", key, keys, element, value, key)
+		} else {
+			// Keys are sorted by their encoded names, the elements are looked up by name.
+			elements := fmt.Sprintf("elements%d", depth)
+			fmt.Fprintf(b, "%s := make(map[string]%s, len(%s))\n", elements, goType(typ.ElementType), value)
+			fmt.Fprintf(b, "for %s, %s := range %s {\n", key, element, value)
+			keyName := fmt.Sprintf("name%d", depth)
+			fmt.Fprintf(b, "%s, err := disorder.MarshalKey(%s)\nif err != nil {\nreturn err\n}\n", keyName, key)
+			fmt.Fprintf(b, "%s = append(%s, %s)\n%s[%s] = %s\n}\n", keys, keys, keyName, elements, keyName, element)
+			fmt.Fprintf(b, "e.SortKeys(%s)\n", keys)
+			fmt.Fprintf(b, "for _, %s := range %s {\n%s := %s[%s]\n", key, keys, element, elements, key)
+		}
+		b.WriteString(encodeValue(typ.ElementType, element, key, depth+1))
+		b.WriteString("}\nreturn nil\n}); err != nil {\nreturn err\n}\n")
+		b.WriteString(encodeNull(name))
+
+	default:
+		fmt.Fprintf(b, "if err := e.%s(%s, %s); err != nil {\nreturn err\n}\n", goWriters[typ.Type], name, value)
+	}
+	return b.String()
+}
+
+// encodeNull closes the nil check of a value, nil array elements are written as null
+// while nil fields and map values are left to the encoder options.
+func encodeNull(name string) string {
+	writer := "WriteNullField"
+	if name == `""` {
+		writer = "WriteNull"
+	}
+	return fmt.Sprintf("} else if err := e.%s(%s); err != nil {\nreturn err\n}\n", writer, name)
+}
+
+// decodeValue generates statements reading the current value of decoder "d" into value,
+// errors are returned from the enclosing function.
+func decodeValue(typ *schema.TypeInfo, value string, depth int) string {
+	b := &strings.Builder{}
+	switch typ.Type {
+	case schema.TypeBytes, schema.TypeTimestamp, schema.TypeTimestampNs, schema.TypeEnum, schema.TypeObject, schema.TypeArray, schema.TypeMap:
+		fmt.Fprintf(b, "if d.IsNull() {\n%s = nil\n} else {\n", value)
+		b.WriteString(decodeNotNull(typ, value, depth))
+		b.WriteString("}\n")
+
+	default:
+		fmt.Fprintf(b, "if err := d.%s(&%s); err != nil {\nreturn err\n}\n", goReaders[typ.Type], value)
+	}
+	return b.String()
+}
+
+// decodeNotNull generates statements reading a value of a nullable type which is not null.
+func decodeNotNull(typ *schema.TypeInfo, value string, depth int) string {
+	b := &strings.Builder{}
+	switch typ.Type {
+	case schema.TypeBytes:
+		fmt.Fprintf(b, "if err := d.ReadBytes(&%s); err != nil {\nreturn err\n}\n", value)
+
+	case schema.TypeTimestamp, schema.TypeTimestampNs, schema.TypeEnum, schema.TypeObject:
+		fmt.Fprintf(b, "if %s == nil {\n%s = new(%s)\n}\n", value, value, goType(typ)[1:])
+		b.WriteString(decodePointer(typ, value))
+
+	case schema.TypeArray:
+		fmt.Fprintf(b, "if %s == nil || d.Mode() != disorder.DecodeAppend {\n%s = %s{}\n}\n", value, value, goType(typ))
+		b.WriteString(decodeContainer(typ, value, depth))
+
+	case schema.TypeMap:
+		fmt.Fprintf(b, "if %s == nil || d.Mode() == disorder.DecodeReplace {\n%s = %s{}\n}\n", value, value, goType(typ))
+		b.WriteString(decodeContainer(typ, value, depth))
+	}
+	return b.String()
+}
+
+// decodeContainer generates statements reading the elements of an array or map into an allocated value.
+func decodeContainer(typ *schema.TypeInfo, value string, depth int) string {
+	b := &strings.Builder{}
+	element := fmt.Sprintf("v%d", depth)
+	if typ.Type == schema.TypeArray {
+		b.WriteString("if err := d.ReadArray(func() error {\n")
+		b.WriteString(decodeElement(typ.ElementType, element, depth+1))
+		fmt.Fprintf(b, "%s = append(%s, %s)\n", value, value, element)
+	} else {
+		key := fmt.Sprintf("k%d", depth)
+		fmt.Fprintf(b, "if err := d.ReadMap(func(%s string) error {\n", key)
+		if typ.KeyType != nil {
+			name := key
+			key = fmt.Sprintf("key%d", depth)
+			fmt.Fprintf(b, "var %s %s\n", key, goKeyType(typ))
+			fmt.Fprintf(b, "if err := disorder.UnmarshalKey(%s, &%s); err != nil {\nreturn err\n}\n", name, key)
+		}
+		b.WriteString(decodeElement(typ.ElementType, element, depth+1))
+		fmt.Fprintf(b, "%s[%s] = %s\n", value, key, element)
+	}
+	b.WriteString("return nil\n}); err != nil {\nreturn err\n}\n")
+	return b.String()
+}
+
+// decodeElement generates a new container element and the statements reading into it,
+// a null element is left nil.
+func decodeElement(typ *schema.TypeInfo, element string, depth int) string {
+	declare := fmt.Sprintf("var %s %s\n", element, goType(typ))
+	switch typ.Type {
+	case schema.TypeTimestamp, schema.TypeTimestampNs, schema.TypeEnum, schema.TypeObject:
+		return declare + fmt.Sprintf("if !d.IsNull() {\n%s = new(%s)\n", element, goType(typ)[1:]) + decodePointer(typ, element) + "}\n"
+	case schema.TypeArray, schema.TypeMap:
+		return declare + fmt.Sprintf("if !d.IsNull() {\n%s = %s{}\n", element, goType(typ)) + decodeContainer(typ, element, depth) + "}\n"
+	default:
+		return declare + decodeValue(typ, element, depth)
+	}
+}
+
+func decodePointer(typ *schema.TypeInfo, value string) string {
+	if typ.Type == schema.TypeTimestamp || typ.Type == schema.TypeTimestampNs {
+		return fmt.Sprintf("if err := d.ReadTime(%s); err != nil {\nreturn err\n}\n", value)
+	}
+	return fmt.Sprintf("if err := %s.UnmarshalDisorder(d); err != nil {\nreturn err\n}\n", value)
+}
@@ -6,6 +6,7 @@ import (
 	"go/format"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
@@ -18,6 +19,8 @@ import (
 const (
 	golang          = "golang"
 	goPackagePrefix = "go_package_prefix"
+	goUnknownFields = "go_unknown_fields"
+	goPresence      = "go_presence"
 )
 
 func NewGoGenerator() generator.Generator {
@@ -35,12 +38,16 @@ type goSchema struct {
 	Schema        *schema.File
 	DefineImports []string
 	RpcImports    []string
+	UnknownFields bool
+	Presence      bool
 }
 
 func (g *goGenerator) Generate(dir string, files map[string]*schema.File, qualifiedPath map[string]string) error {
 	for _, file := range files {
 		schemaFile := &goSchema{
-			Schema: file,
+			Schema:        file,
+			UnknownFields: file.Options[goUnknownFields] == "true",
+			Presence:      file.Options[goPresence] == "true",
 		}
 
 		defineImports := make(map[string]bool)
@@ -59,6 +66,10 @@ func (g *goGenerator) Generate(dir string, files map[string]*schema.File, qualif
 		if len(file.Enums) > 0 {
 			defineImports["fmt"] = true
 		}
+		if len(file.Enums) > 0 || len(file.Messages) > 0 {
+			defineImports["github.com/meerkat-io/disorder"] = true
+		}
+		rpcImports["errors"] = true
 		rpcImports["fmt"] = true
 		rpcImports["github.com/meerkat-io/disorder"] = true
 		rpcImports["github.com/meerkat-io/disorder/rpc"] = true
@@ -69,6 +80,8 @@ func (g *goGenerator) Generate(dir string, files map[string]*schema.File, qualif
 		for path := range rpcImports {
 			schemaFile.RpcImports = append(schemaFile.RpcImports, path)
 		}
+		sort.Strings(schemaFile.DefineImports)
+		sort.Strings(schemaFile.RpcImports)
 
 		schemaDir, err := filepath.Abs(filepath.Join(dir, g.packageFolder(file.Package)))
 		if err != nil {
@@ -123,7 +136,8 @@ func (g *goGenerator) packageFolder(pkg string) string {
 }
 
 func (g *goGenerator) resolveImport(typeInfo *schema.TypeInfo, importMap map[string]bool, current *schema.File, files map[string]*schema.File, qualifiedPath map[string]string) {
-	if typeInfo.Type == schema.TypeTimestamp {
+	switch typeInfo.Type {
+	case schema.TypeTimestamp, schema.TypeTimestampNs, schema.TypeDuration:
 		importMap["time"] = true
 	}
 	if typeInfo.Qualified != "" && current.FilePath != qualifiedPath[typeInfo.Qualified] {
@@ -134,4 +148,10 @@ func (g *goGenerator) resolveImport(typeInfo *schema.TypeInfo, importMap map[str
 		}
 		importMap[filepath.Join(prefix, g.packageFolder(targetFile.Package))] = true
 	}
+	if typeInfo.KeyType != nil {
+		g.resolveImport(typeInfo.KeyType, importMap, current, files, qualifiedPath)
+	}
+	if typeInfo.ElementType != nil {
+		g.resolveImport(typeInfo.ElementType, importMap, current, files, qualifiedPath)
+	}
 }
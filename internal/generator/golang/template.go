@@ -30,7 +30,7 @@ func (g *goGenerator) initTemplete() {
 		},
 		"IsPointer": func(typ *schema.TypeInfo) bool {
 			switch typ.Type {
-			case schema.TypeEnum, schema.TypeTimestamp, schema.TypeObject:
+			case schema.TypeEnum, schema.TypeTimestamp, schema.TypeTimestampNs, schema.TypeObject:
 				return true
 			default:
 				return false
@@ -40,20 +40,28 @@ func (g *goGenerator) initTemplete() {
 			switch typ.Type {
 			case schema.TypeEnum:
 				return fmt.Sprintf(" = new(%s)", goType(typ)[1:])
-			case schema.TypeTimestamp:
+			case schema.TypeTimestamp, schema.TypeTimestampNs:
 				return " = &time.Time{}"
 			case schema.TypeObject:
 				return fmt.Sprintf(" = &%s{}", goType(typ)[1:])
 			case schema.TypeMap:
-				return fmt.Sprintf(" = make(map[string]%s)", goType(typ.ElementType))
+				return fmt.Sprintf(" = make(%s)", goType(typ))
 			default:
 				return ""
 			}
 		},
+		"Encode": func(field *schema.Field) string {
+			code := encodeValue(field.Type, "m."+strcase.PascalCase(field.Name), fmt.Sprintf("%q", field.Name), 0)
+			return strings.TrimSuffix(code, "\n")
+		},
+		"Decode": func(field *schema.Field) string {
+			code := decodeValue(field.Type, "m."+strcase.PascalCase(field.Name), 0)
+			return strings.TrimSuffix(code, "\n")
+		},
 		"Tag": func(typ *schema.TypeInfo, name string) string {
 			omitEmpty := ""
 			switch typ.Type {
-			case schema.TypeTimestamp, schema.TypeEnum, schema.TypeObject, schema.TypeArray, schema.TypeMap:
+			case schema.TypeTimestamp, schema.TypeTimestampNs, schema.TypeEnum, schema.TypeObject, schema.TypeArray, schema.TypeMap:
 				omitEmpty = ",omitempty"
 			}
 			return fmt.Sprintf("`disorder:\"%s\" json:\"%s%s\"`", name, name, omitEmpty)
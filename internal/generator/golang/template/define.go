@@ -19,7 +19,7 @@ const (
 {{- end}}
 )
 
-var {{CamelCase $enum.Name}}EnumMap = map[string]Color {
+var {{CamelCase $enum.Name}}EnumMap = map[string]{{PascalCase $enum.Name}}{
 {{- range .Values}}
 	"{{.}}":{{PascalCase $enum.Name}}{{PascalCase .}},
 {{- end}}
@@ -27,7 +27,7 @@ var {{CamelCase $enum.Name}}EnumMap = map[string]Color {
 
 func (*{{PascalCase $enum.Name}}) Enum() {}
 
-func (enum *{{PascalCase $enum.Name}}) FromString(value string) error {
+func (enum *{{PascalCase $enum.Name}}) SetValue(value string) error {
 	if value == "" {
 		return fmt.Errorf("empty enum value")
 	}
@@ -41,7 +41,7 @@ func (enum *{{PascalCase $enum.Name}}) FromString(value string) error {
 	return fmt.Errorf("invalid enum value: %s", value)
 }
 
-func (enum *{{PascalCase $enum.Name}}) ToString() (string, error) {
+func (enum *{{PascalCase $enum.Name}}) GetValue() (string, error) {
 	name := string(*enum)
 	if len(name) == 0 {
 		return "", fmt.Errorf("empty enum value")
@@ -54,6 +54,14 @@ func (enum *{{PascalCase $enum.Name}}) ToString() (string, error) {
 	}
 	return "", fmt.Errorf("invalid enum value: %s", name)
 }
+
+func (enum *{{PascalCase $enum.Name}}) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteEnum(name, enum)
+}
+
+func (enum *{{PascalCase $enum.Name}}) UnmarshalDisorder(d *disorder.Decoder) error {
+	return d.ReadEnum(enum)
+}
 {{- end}}
 {{- range .Schema.Messages}}
 
@@ -61,6 +69,71 @@ type {{PascalCase .Name}} struct {
 	{{- range .Fields}}
 	{{PascalCase .Name}} {{Type .Type}} {{Tag .Type .Name}}
 	{{- end}}
+	{{- if $.UnknownFields}}
+
+	// XXX_unknown keeps the fields missing from the schema for re-encoding.
+	XXX_unknown disorder.Unknown ` + "`json:\"-\"`" + `
+	{{- end}}
+	{{- if $.Presence}}
+
+	absent [{{len .Fields}}]bool
+	{{- end}}
+}
+{{- if $.Presence}}
+{{- $message := .}}
+{{- range $i, $field := .Fields}}
+
+// Has{{PascalCase $field.Name}} reports whether {{$field.Name}} was present in the last decoded data, null included.
+// Messages which were not decoded report every field as present.
+func (m *{{PascalCase $message.Name}}) Has{{PascalCase $field.Name}}() bool {
+	return !m.absent[{{$i}}]
+}
+{{- end}}
+{{- end}}
+
+func (m *{{PascalCase .Name}}) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteObject(name, func() error {
+		{{- range .Fields}}
+		{{Encode .}}
+		{{- end}}
+		{{- if $.UnknownFields}}
+		return e.WriteUnknown(m.XXX_unknown)
+		{{- else}}
+		return nil
+		{{- end}}
+	})
+}
+
+func (m *{{PascalCase .Name}}) UnmarshalDisorder(d *disorder.Decoder) error {
+	if d.Mode() == disorder.DecodeReplace {
+		*m = {{PascalCase .Name}}{}
+	}
+	{{- if $.Presence}}
+	for i := range m.absent {
+		m.absent[i] = true
+	}
+	{{- end}}
+	{{- if $.UnknownFields}}
+	m.XXX_unknown = nil
+	{{- end}}
+	return d.ReadObject(func(key string) error {
+		switch key {
+		{{- range $i, $field := .Fields}}
+		case "{{$field.Name}}":
+			{{- if $.Presence}}
+			m.absent[{{$i}}] = false
+			{{- end}}
+			{{Decode $field}}
+		{{- end}}
+		default:
+			{{- if $.UnknownFields}}
+			return d.ReadUnknown(key, m, &m.XXX_unknown)
+			{{- else}}
+			return d.SkipUnknown(key, m)
+			{{- end}}
+		}
+		return nil
+	})
 }
 {{- end}}`
 )
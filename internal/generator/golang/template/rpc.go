@@ -74,6 +74,12 @@ func (s *{{CamelCase $service.Name}}Server) {{CamelCase .Name}}(context *rpc.Con
 	var request {{Type .Input}}{{InitType .Input}}
 	err := d.Decode({{if not (IsPointer .Input)}}&{{end}}request)
 	if err != nil {
+		if errors.Is(err, disorder.ErrLimitExceeded) {
+			return nil, &rpc.Error{
+				Code:  code.ResourceExhausted,
+				Error: err,
+			}
+		}
 		return nil, &rpc.Error{
 			Code:  code.InvalidRequest,
 			Error: err,
@@ -9,14 +9,20 @@ import (
 )
 
 var goTypes = map[schema.Type]string{
-	schema.TypeBool:      "bool",
-	schema.TypeInt:       "int32",
-	schema.TypeLong:      "int64",
-	schema.TypeFloat:     "float32",
-	schema.TypeDouble:    "float64",
-	schema.TypeString:    "string",
-	schema.TypeBytes:     "[]byte",
-	schema.TypeTimestamp: "*time.Time",
+	schema.TypeBool:        "bool",
+	schema.TypeInt:         "int32",
+	schema.TypeLong:        "int64",
+	schema.TypeUint:        "uint32",
+	schema.TypeUlong:       "uint64",
+	schema.TypeShort:       "int16",
+	schema.TypeUshort:      "uint16",
+	schema.TypeFloat:       "float32",
+	schema.TypeDouble:      "float64",
+	schema.TypeString:      "string",
+	schema.TypeBytes:       "[]byte",
+	schema.TypeTimestamp:   "*time.Time",
+	schema.TypeTimestampNs: "*time.Time",
+	schema.TypeDuration:    "time.Duration",
 }
 
 func goType(typ *schema.TypeInfo) string {
@@ -24,7 +30,7 @@ func goType(typ *schema.TypeInfo) string {
 	case schema.TypeArray:
 		return fmt.Sprintf("[]%s", goType(typ.ElementType))
 	case schema.TypeMap:
-		return fmt.Sprintf("map[string]%s", goType(typ.ElementType))
+		return fmt.Sprintf("map[%s]%s", goKeyType(typ), goType(typ.ElementType))
 	default:
 		if typ.Type.IsPrimary() {
 			return goTypes[typ.Type]
@@ -38,3 +44,11 @@ func goType(typ *schema.TypeInfo) string {
 	}
 	return fmt.Sprintf("*%s", strcase.PascalCase(typ.TypeRef))
 }
+
+// goKeyType is the key type of a map, enums are keyed by value.
+func goKeyType(typ *schema.TypeInfo) string {
+	if typ.KeyType == nil {
+		return "string"
+	}
+	return strings.TrimPrefix(goType(typ.KeyType), "*")
+}
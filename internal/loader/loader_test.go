@@ -1,6 +1,9 @@
 package loader_test
 
 import (
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/meerkat-io/disorder/internal/loader"
@@ -26,3 +29,35 @@ func TestLoader(t *testing.T) {
 	assert.Equal(t, "value", file.Messages[1].Fields[0].Name)
 	assert.Equal(t, "number", file.Messages[1].Fields[0].Type.TypeRef)
 }
+
+func TestMapKeyTypes(t *testing.T) {
+	files, _, err := loader.NewLoader().Load("../test_data/schema.yaml")
+	assert.Nil(t, err)
+	fields := map[string]*schema.TypeInfo{}
+	for _, file := range files {
+		for _, message := range file.Messages {
+			for _, field := range message.Fields {
+				fields[field.Name] = field.Type
+			}
+		}
+	}
+	assert.Nil(t, fields["int_map"].KeyType)
+	assert.Equal(t, schema.TypeLong, fields["long_map"].KeyType.Type)
+	assert.Equal(t, schema.TypeString, fields["long_map"].ElementType.Type)
+	assert.Equal(t, schema.TypeEnum, fields["enum_map"].KeyType.Type)
+	assert.Equal(t, schema.TypeUshort, fields["enum_map"].ElementType.KeyType.Type)
+	assert.Equal(t, schema.TypeObject, fields["enum_map"].ElementType.ElementType.Type)
+
+	dir := t.TempDir()
+	for i, typ := range []string{"map[int, int]", "map[double, int]", "map[array[int], int]", "map[number, int]", "map[missing, int]"} {
+		file := filepath.Join(dir, fmt.Sprintf("invalid%d.yaml", i))
+		content := fmt.Sprintf("schema: disorder\npackage: invalid\nmessages:\n  number:\n    value: int\n  holder:\n    field: %s\n", typ)
+		assert.Nil(t, os.WriteFile(file, []byte(content), 0666))
+		_, _, err = loader.NewLoader().Load(file)
+		if i == 0 {
+			assert.Nil(t, err, typ)
+		} else {
+			assert.NotNil(t, err, typ)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package loader
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/meerkat-io/disorder/internal/schema"
 )
@@ -210,6 +211,22 @@ func (p *parser) parseType(pkg, typ string) (t *schema.TypeInfo, err error) {
 	} else if p.validator.isMapType(typ) {
 		t.Type = schema.TypeMap
 		elementType := typ[4 : len(typ)-1]
+		if comma := strings.Index(elementType, ","); comma >= 0 && !strings.Contains(elementType[:comma], "[") {
+			keyType := strings.TrimSpace(elementType[:comma])
+			elementType = strings.TrimSpace(elementType[comma+1:])
+			if !p.validator.isSingularType(keyType) {
+				err = fmt.Errorf("invalid map key type %s", keyType)
+				return
+			}
+			t.KeyType, err = p.parseType(pkg, keyType)
+			if err != nil {
+				return
+			}
+			if t.KeyType.Type != schema.TypeUndefined && !t.KeyType.Type.IsKey() {
+				err = fmt.Errorf("invalid map key type %s", keyType)
+				return
+			}
+		}
 		t.ElementType, err = p.parseType(pkg, elementType)
 		return
 	}
@@ -87,6 +87,14 @@ func (r *resolver) resolveType(file *schema.File, info *schema.TypeInfo) error {
 		}
 	} else if info.ElementType != nil {
 		// array or map
+		if info.KeyType != nil {
+			if err := r.resolveType(file, info.KeyType); err != nil {
+				return err
+			}
+			if info.KeyType.Type == schema.TypeObject {
+				return fmt.Errorf("invalid map key type \"%s\"", info.KeyType.TypeRef)
+			}
+		}
 		return r.resolveType(file, info.ElementType)
 	}
 	return nil
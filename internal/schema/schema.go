@@ -11,30 +11,53 @@ const (
 	TypeFloat  Type = 4
 	TypeDouble Type = 5
 	TypeBytes  Type = 6
+	TypeUint   Type = 7
+	TypeUlong  Type = 8
+	TypeShort  Type = 9
+	TypeUshort Type = 10
 
 	TypeString    Type = 11
 	TypeTimestamp Type = 12
 	TypeEnum      Type = 13
 
+	TypeTimestampNs Type = 14
+	TypeDuration    Type = 15
+
 	TypeArray  Type = 21
 	TypeMap    Type = 22
 	TypeObject Type = 23
 )
 
 func (t Type) IsPrimary() bool {
-	return t >= TypeBool && t <= TypeTimestamp
+	return (t >= TypeBool && t <= TypeTimestamp) || t == TypeTimestampNs || t == TypeDuration
+}
+
+// IsKey reports whether t can be the key type of a map, enums are valid keys as well.
+func (t Type) IsKey() bool {
+	switch t {
+	case TypeString, TypeInt, TypeLong, TypeUint, TypeUlong, TypeShort, TypeUshort:
+		return true
+	default:
+		return false
+	}
 }
 
 var (
 	PrimaryTypes = map[string]Type{
-		"bool":      TypeBool,
-		"int":       TypeInt,
-		"long":      TypeLong,
-		"float":     TypeFloat,
-		"double":    TypeDouble,
-		"bytes":     TypeBytes,
-		"string":    TypeString,
-		"timestamp": TypeTimestamp,
+		"bool":         TypeBool,
+		"int":          TypeInt,
+		"long":         TypeLong,
+		"float":        TypeFloat,
+		"double":       TypeDouble,
+		"bytes":        TypeBytes,
+		"uint":         TypeUint,
+		"ulong":        TypeUlong,
+		"short":        TypeShort,
+		"ushort":       TypeUshort,
+		"string":       TypeString,
+		"timestamp":    TypeTimestamp,
+		"timestamp_ns": TypeTimestampNs,
+		"duration":     TypeDuration,
 	}
 )
 
@@ -43,6 +66,8 @@ type TypeInfo struct {
 	TypeRef     string
 	Qualified   string
 	ElementType *TypeInfo
+	// KeyType is the key type of a map declared as map[key,value], nil for string keys.
+	KeyType *TypeInfo
 }
 
 type Field struct {
@@ -3,6 +3,7 @@ package test
 
 import (
 	"fmt"
+	"github.com/meerkat-io/disorder"
 	"github.com/meerkat-io/disorder/internal/test_data/test/sub"
 	"time"
 )
@@ -23,7 +24,7 @@ var colorEnumMap = map[string]Color{
 
 func (*Color) Enum() {}
 
-func (enum *Color) FromString(value string) error {
+func (enum *Color) SetValue(value string) error {
 	if value == "" {
 		return fmt.Errorf("empty enum value")
 	}
@@ -37,7 +38,7 @@ func (enum *Color) FromString(value string) error {
 	return fmt.Errorf("invalid enum value: %s", value)
 }
 
-func (enum *Color) ToString() (string, error) {
+func (enum *Color) GetValue() (string, error) {
 	name := string(*enum)
 	if len(name) == 0 {
 		return "", fmt.Errorf("empty enum value")
@@ -51,27 +52,853 @@ func (enum *Color) ToString() (string, error) {
 	return "", fmt.Errorf("invalid enum value: %s", name)
 }
 
+func (enum *Color) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteEnum(name, enum)
+}
+
+func (enum *Color) UnmarshalDisorder(d *disorder.Decoder) error {
+	return d.ReadEnum(enum)
+}
+
 type Object struct {
-	IntField    int32                                       `disorder:"int_field" json:"int_field"`
-	StringField string                                      `disorder:"string_field" json:"string_field"`
-	BytesFields []byte                                      `disorder:"bytes_fields" json:"bytes_fields"`
-	EnumField   *Color                                      `disorder:"enum_field" json:"enum_field,omitempty"`
-	TimeField   *time.Time                                  `disorder:"time_field" json:"time_field,omitempty"`
-	ObjField    *sub.NumberWrapper                          `disorder:"obj_field" json:"obj_field,omitempty"`
-	IntArray    []int32                                     `disorder:"int_array" json:"int_array,omitempty"`
-	IntMap      map[string]int32                            `disorder:"int_map" json:"int_map,omitempty"`
-	ObjArray    []*sub.NumberWrapper                        `disorder:"obj_array" json:"obj_array,omitempty"`
-	ObjMap      map[string]*sub.NumberWrapper               `disorder:"obj_map" json:"obj_map,omitempty"`
-	EmptyString string                                      `disorder:"empty_string" json:"empty_string"`
-	EmptyEnum   *Color                                      `disorder:"empty_enum" json:"empty_enum,omitempty"`
-	EmptyTime   *time.Time                                  `disorder:"empty_time" json:"empty_time,omitempty"`
-	EmptyObj    *sub.NumberWrapper                          `disorder:"empty_obj" json:"empty_obj,omitempty"`
-	EmptyArray  []int32                                     `disorder:"empty_array" json:"empty_array,omitempty"`
-	EmptyMap    map[string]int32                            `disorder:"empty_map" json:"empty_map,omitempty"`
-	Nested      map[string]map[string][][]map[string]*Color `disorder:"nested" json:"nested,omitempty"`
+	IntField      int32                                       `disorder:"int_field" json:"int_field"`
+	UintField     uint32                                      `disorder:"uint_field" json:"uint_field"`
+	UlongField    uint64                                      `disorder:"ulong_field" json:"ulong_field"`
+	ShortField    int16                                       `disorder:"short_field" json:"short_field"`
+	UshortField   uint16                                      `disorder:"ushort_field" json:"ushort_field"`
+	StringField   string                                      `disorder:"string_field" json:"string_field"`
+	BytesFields   []byte                                      `disorder:"bytes_fields" json:"bytes_fields"`
+	EnumField     *Color                                      `disorder:"enum_field" json:"enum_field,omitempty"`
+	TimeField     *time.Time                                  `disorder:"time_field" json:"time_field,omitempty"`
+	TimeNsField   *time.Time                                  `disorder:"time_ns_field" json:"time_ns_field,omitempty"`
+	DurationField time.Duration                               `disorder:"duration_field" json:"duration_field"`
+	ObjField      *sub.NumberWrapper                          `disorder:"obj_field" json:"obj_field,omitempty"`
+	IntArray      []int32                                     `disorder:"int_array" json:"int_array,omitempty"`
+	IntMap        map[string]int32                            `disorder:"int_map" json:"int_map,omitempty"`
+	ObjArray      []*sub.NumberWrapper                        `disorder:"obj_array" json:"obj_array,omitempty"`
+	ObjMap        map[string]*sub.NumberWrapper               `disorder:"obj_map" json:"obj_map,omitempty"`
+	EmptyString   string                                      `disorder:"empty_string" json:"empty_string"`
+	EmptyEnum     *Color                                      `disorder:"empty_enum" json:"empty_enum,omitempty"`
+	EmptyTime     *time.Time                                  `disorder:"empty_time" json:"empty_time,omitempty"`
+	EmptyObj      *sub.NumberWrapper                          `disorder:"empty_obj" json:"empty_obj,omitempty"`
+	EmptyArray    []int32                                     `disorder:"empty_array" json:"empty_array,omitempty"`
+	EmptyMap      map[string]int32                            `disorder:"empty_map" json:"empty_map,omitempty"`
+	Nested        map[string]map[string][][]map[string]*Color `disorder:"nested" json:"nested,omitempty"`
+	LongMap       map[int64]string                            `disorder:"long_map" json:"long_map,omitempty"`
+	EnumMap       map[Color]map[uint16]*sub.NumberWrapper     `disorder:"enum_map" json:"enum_map,omitempty"`
+}
+
+func (m *Object) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteObject(name, func() error {
+		if err := e.WriteInt("int_field", m.IntField); err != nil {
+			return err
+		}
+		if err := e.WriteUint("uint_field", m.UintField); err != nil {
+			return err
+		}
+		if err := e.WriteUlong("ulong_field", m.UlongField); err != nil {
+			return err
+		}
+		if err := e.WriteShort("short_field", m.ShortField); err != nil {
+			return err
+		}
+		if err := e.WriteUshort("ushort_field", m.UshortField); err != nil {
+			return err
+		}
+		if err := e.WriteString("string_field", m.StringField); err != nil {
+			return err
+		}
+		if m.BytesFields != nil {
+			if err := e.WriteBytes("bytes_fields", m.BytesFields); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("bytes_fields"); err != nil {
+			return err
+		}
+		if m.EnumField != nil {
+			if err := m.EnumField.MarshalDisorder(e, "enum_field"); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("enum_field"); err != nil {
+			return err
+		}
+		if m.TimeField != nil {
+			if err := e.WriteTime("time_field", m.TimeField); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("time_field"); err != nil {
+			return err
+		}
+		if m.TimeNsField != nil {
+			if err := e.WriteTimeNs("time_ns_field", m.TimeNsField); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("time_ns_field"); err != nil {
+			return err
+		}
+		if err := e.WriteDuration("duration_field", m.DurationField); err != nil {
+			return err
+		}
+		if m.ObjField != nil {
+			if err := m.ObjField.MarshalDisorder(e, "obj_field"); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("obj_field"); err != nil {
+			return err
+		}
+		if m.IntArray != nil {
+			if err := e.WriteArray("int_array", func() error {
+				for _, v0 := range m.IntArray {
+					if err := e.WriteInt("", v0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("int_array"); err != nil {
+			return err
+		}
+		if m.IntMap != nil {
+			if err := e.WriteObject("int_map", func() error {
+				keys0 := make([]string, 0, len(m.IntMap))
+				for k0 := range m.IntMap {
+					keys0 = append(keys0, k0)
+				}
+				e.SortKeys(keys0)
+				for _, k0 := range keys0 {
+					v0 := m.IntMap[k0]
+					if err := e.WriteInt(k0, v0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("int_map"); err != nil {
+			return err
+		}
+		if m.ObjArray != nil {
+			if err := e.WriteArray("obj_array", func() error {
+				for _, v0 := range m.ObjArray {
+					if v0 != nil {
+						if err := v0.MarshalDisorder(e, ""); err != nil {
+							return err
+						}
+					} else if err := e.WriteNull(""); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("obj_array"); err != nil {
+			return err
+		}
+		if m.ObjMap != nil {
+			if err := e.WriteObject("obj_map", func() error {
+				keys0 := make([]string, 0, len(m.ObjMap))
+				for k0 := range m.ObjMap {
+					keys0 = append(keys0, k0)
+				}
+				e.SortKeys(keys0)
+				for _, k0 := range keys0 {
+					v0 := m.ObjMap[k0]
+					if v0 != nil {
+						if err := v0.MarshalDisorder(e, k0); err != nil {
+							return err
+						}
+					} else if err := e.WriteNullField(k0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("obj_map"); err != nil {
+			return err
+		}
+		if err := e.WriteString("empty_string", m.EmptyString); err != nil {
+			return err
+		}
+		if m.EmptyEnum != nil {
+			if err := m.EmptyEnum.MarshalDisorder(e, "empty_enum"); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("empty_enum"); err != nil {
+			return err
+		}
+		if m.EmptyTime != nil {
+			if err := e.WriteTime("empty_time", m.EmptyTime); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("empty_time"); err != nil {
+			return err
+		}
+		if m.EmptyObj != nil {
+			if err := m.EmptyObj.MarshalDisorder(e, "empty_obj"); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("empty_obj"); err != nil {
+			return err
+		}
+		if m.EmptyArray != nil {
+			if err := e.WriteArray("empty_array", func() error {
+				for _, v0 := range m.EmptyArray {
+					if err := e.WriteInt("", v0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("empty_array"); err != nil {
+			return err
+		}
+		if m.EmptyMap != nil {
+			if err := e.WriteObject("empty_map", func() error {
+				keys0 := make([]string, 0, len(m.EmptyMap))
+				for k0 := range m.EmptyMap {
+					keys0 = append(keys0, k0)
+				}
+				e.SortKeys(keys0)
+				for _, k0 := range keys0 {
+					v0 := m.EmptyMap[k0]
+					if err := e.WriteInt(k0, v0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("empty_map"); err != nil {
+			return err
+		}
+		if m.Nested != nil {
+			if err := e.WriteObject("nested", func() error {
+				keys0 := make([]string, 0, len(m.Nested))
+				for k0 := range m.Nested {
+					keys0 = append(keys0, k0)
+				}
+				e.SortKeys(keys0)
+				for _, k0 := range keys0 {
+					v0 := m.Nested[k0]
+					if v0 != nil {
+						if err := e.WriteObject(k0, func() error {
+							keys1 := make([]string, 0, len(v0))
+							for k1 := range v0 {
+								keys1 = append(keys1, k1)
+							}
+							e.SortKeys(keys1)
+							for _, k1 := range keys1 {
+								v1 := v0[k1]
+								if v1 != nil {
+									if err := e.WriteArray(k1, func() error {
+										for _, v2 := range v1 {
+											if v2 != nil {
+												if err := e.WriteArray("", func() error {
+													for _, v3 := range v2 {
+														if v3 != nil {
+															if err := e.WriteObject("", func() error {
+																keys4 := make([]string, 0, len(v3))
+																for k4 := range v3 {
+																	keys4 = append(keys4, k4)
+																}
+																e.SortKeys(keys4)
+																for _, k4 := range keys4 {
+																	v4 := v3[k4]
+																	if v4 != nil {
+																		if err := v4.MarshalDisorder(e, k4); err != nil {
+																			return err
+																		}
+																	} else if err := e.WriteNullField(k4); err != nil {
+																		return err
+																	}
+																}
+																return nil
+															}); err != nil {
+																return err
+															}
+														} else if err := e.WriteNull(""); err != nil {
+															return err
+														}
+													}
+													return nil
+												}); err != nil {
+													return err
+												}
+											} else if err := e.WriteNull(""); err != nil {
+												return err
+											}
+										}
+										return nil
+									}); err != nil {
+										return err
+									}
+								} else if err := e.WriteNullField(k1); err != nil {
+									return err
+								}
+							}
+							return nil
+						}); err != nil {
+							return err
+						}
+					} else if err := e.WriteNullField(k0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("nested"); err != nil {
+			return err
+		}
+		if m.LongMap != nil {
+			if err := e.WriteObject("long_map", func() error {
+				keys0 := make([]string, 0, len(m.LongMap))
+				elements0 := make(map[string]string, len(m.LongMap))
+				for k0, v0 := range m.LongMap {
+					name0, err := disorder.MarshalKey(k0)
+					if err != nil {
+						return err
+					}
+					keys0 = append(keys0, name0)
+					elements0[name0] = v0
+				}
+				e.SortKeys(keys0)
+				for _, k0 := range keys0 {
+					v0 := elements0[k0]
+					if err := e.WriteString(k0, v0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("long_map"); err != nil {
+			return err
+		}
+		if m.EnumMap != nil {
+			if err := e.WriteObject("enum_map", func() error {
+				keys0 := make([]string, 0, len(m.EnumMap))
+				elements0 := make(map[string]map[uint16]*sub.NumberWrapper, len(m.EnumMap))
+				for k0, v0 := range m.EnumMap {
+					name0, err := disorder.MarshalKey(k0)
+					if err != nil {
+						return err
+					}
+					keys0 = append(keys0, name0)
+					elements0[name0] = v0
+				}
+				e.SortKeys(keys0)
+				for _, k0 := range keys0 {
+					v0 := elements0[k0]
+					if v0 != nil {
+						if err := e.WriteObject(k0, func() error {
+							keys1 := make([]string, 0, len(v0))
+							elements1 := make(map[string]*sub.NumberWrapper, len(v0))
+							for k1, v1 := range v0 {
+								name1, err := disorder.MarshalKey(k1)
+								if err != nil {
+									return err
+								}
+								keys1 = append(keys1, name1)
+								elements1[name1] = v1
+							}
+							e.SortKeys(keys1)
+							for _, k1 := range keys1 {
+								v1 := elements1[k1]
+								if v1 != nil {
+									if err := v1.MarshalDisorder(e, k1); err != nil {
+										return err
+									}
+								} else if err := e.WriteNullField(k1); err != nil {
+									return err
+								}
+							}
+							return nil
+						}); err != nil {
+							return err
+						}
+					} else if err := e.WriteNullField(k0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("enum_map"); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func (m *Object) UnmarshalDisorder(d *disorder.Decoder) error {
+	if d.Mode() == disorder.DecodeReplace {
+		*m = Object{}
+	}
+	return d.ReadObject(func(key string) error {
+		switch key {
+		case "int_field":
+			if err := d.ReadInt(&m.IntField); err != nil {
+				return err
+			}
+		case "uint_field":
+			if err := d.ReadUint(&m.UintField); err != nil {
+				return err
+			}
+		case "ulong_field":
+			if err := d.ReadUlong(&m.UlongField); err != nil {
+				return err
+			}
+		case "short_field":
+			if err := d.ReadShort(&m.ShortField); err != nil {
+				return err
+			}
+		case "ushort_field":
+			if err := d.ReadUshort(&m.UshortField); err != nil {
+				return err
+			}
+		case "string_field":
+			if err := d.ReadString(&m.StringField); err != nil {
+				return err
+			}
+		case "bytes_fields":
+			if d.IsNull() {
+				m.BytesFields = nil
+			} else {
+				if err := d.ReadBytes(&m.BytesFields); err != nil {
+					return err
+				}
+			}
+		case "enum_field":
+			if d.IsNull() {
+				m.EnumField = nil
+			} else {
+				if m.EnumField == nil {
+					m.EnumField = new(Color)
+				}
+				if err := m.EnumField.UnmarshalDisorder(d); err != nil {
+					return err
+				}
+			}
+		case "time_field":
+			if d.IsNull() {
+				m.TimeField = nil
+			} else {
+				if m.TimeField == nil {
+					m.TimeField = new(time.Time)
+				}
+				if err := d.ReadTime(m.TimeField); err != nil {
+					return err
+				}
+			}
+		case "time_ns_field":
+			if d.IsNull() {
+				m.TimeNsField = nil
+			} else {
+				if m.TimeNsField == nil {
+					m.TimeNsField = new(time.Time)
+				}
+				if err := d.ReadTime(m.TimeNsField); err != nil {
+					return err
+				}
+			}
+		case "duration_field":
+			if err := d.ReadDuration(&m.DurationField); err != nil {
+				return err
+			}
+		case "obj_field":
+			if d.IsNull() {
+				m.ObjField = nil
+			} else {
+				if m.ObjField == nil {
+					m.ObjField = new(sub.NumberWrapper)
+				}
+				if err := m.ObjField.UnmarshalDisorder(d); err != nil {
+					return err
+				}
+			}
+		case "int_array":
+			if d.IsNull() {
+				m.IntArray = nil
+			} else {
+				if m.IntArray == nil || d.Mode() != disorder.DecodeAppend {
+					m.IntArray = []int32{}
+				}
+				if err := d.ReadArray(func() error {
+					var v0 int32
+					if err := d.ReadInt(&v0); err != nil {
+						return err
+					}
+					m.IntArray = append(m.IntArray, v0)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "int_map":
+			if d.IsNull() {
+				m.IntMap = nil
+			} else {
+				if m.IntMap == nil || d.Mode() == disorder.DecodeReplace {
+					m.IntMap = map[string]int32{}
+				}
+				if err := d.ReadMap(func(k0 string) error {
+					var v0 int32
+					if err := d.ReadInt(&v0); err != nil {
+						return err
+					}
+					m.IntMap[k0] = v0
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "obj_array":
+			if d.IsNull() {
+				m.ObjArray = nil
+			} else {
+				if m.ObjArray == nil || d.Mode() != disorder.DecodeAppend {
+					m.ObjArray = []*sub.NumberWrapper{}
+				}
+				if err := d.ReadArray(func() error {
+					var v0 *sub.NumberWrapper
+					if !d.IsNull() {
+						v0 = new(sub.NumberWrapper)
+						if err := v0.UnmarshalDisorder(d); err != nil {
+							return err
+						}
+					}
+					m.ObjArray = append(m.ObjArray, v0)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "obj_map":
+			if d.IsNull() {
+				m.ObjMap = nil
+			} else {
+				if m.ObjMap == nil || d.Mode() == disorder.DecodeReplace {
+					m.ObjMap = map[string]*sub.NumberWrapper{}
+				}
+				if err := d.ReadMap(func(k0 string) error {
+					var v0 *sub.NumberWrapper
+					if !d.IsNull() {
+						v0 = new(sub.NumberWrapper)
+						if err := v0.UnmarshalDisorder(d); err != nil {
+							return err
+						}
+					}
+					m.ObjMap[k0] = v0
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "empty_string":
+			if err := d.ReadString(&m.EmptyString); err != nil {
+				return err
+			}
+		case "empty_enum":
+			if d.IsNull() {
+				m.EmptyEnum = nil
+			} else {
+				if m.EmptyEnum == nil {
+					m.EmptyEnum = new(Color)
+				}
+				if err := m.EmptyEnum.UnmarshalDisorder(d); err != nil {
+					return err
+				}
+			}
+		case "empty_time":
+			if d.IsNull() {
+				m.EmptyTime = nil
+			} else {
+				if m.EmptyTime == nil {
+					m.EmptyTime = new(time.Time)
+				}
+				if err := d.ReadTime(m.EmptyTime); err != nil {
+					return err
+				}
+			}
+		case "empty_obj":
+			if d.IsNull() {
+				m.EmptyObj = nil
+			} else {
+				if m.EmptyObj == nil {
+					m.EmptyObj = new(sub.NumberWrapper)
+				}
+				if err := m.EmptyObj.UnmarshalDisorder(d); err != nil {
+					return err
+				}
+			}
+		case "empty_array":
+			if d.IsNull() {
+				m.EmptyArray = nil
+			} else {
+				if m.EmptyArray == nil || d.Mode() != disorder.DecodeAppend {
+					m.EmptyArray = []int32{}
+				}
+				if err := d.ReadArray(func() error {
+					var v0 int32
+					if err := d.ReadInt(&v0); err != nil {
+						return err
+					}
+					m.EmptyArray = append(m.EmptyArray, v0)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "empty_map":
+			if d.IsNull() {
+				m.EmptyMap = nil
+			} else {
+				if m.EmptyMap == nil || d.Mode() == disorder.DecodeReplace {
+					m.EmptyMap = map[string]int32{}
+				}
+				if err := d.ReadMap(func(k0 string) error {
+					var v0 int32
+					if err := d.ReadInt(&v0); err != nil {
+						return err
+					}
+					m.EmptyMap[k0] = v0
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "nested":
+			if d.IsNull() {
+				m.Nested = nil
+			} else {
+				if m.Nested == nil || d.Mode() == disorder.DecodeReplace {
+					m.Nested = map[string]map[string][][]map[string]*Color{}
+				}
+				if err := d.ReadMap(func(k0 string) error {
+					var v0 map[string][][]map[string]*Color
+					if !d.IsNull() {
+						v0 = map[string][][]map[string]*Color{}
+						if err := d.ReadMap(func(k1 string) error {
+							var v1 [][]map[string]*Color
+							if !d.IsNull() {
+								v1 = [][]map[string]*Color{}
+								if err := d.ReadArray(func() error {
+									var v2 []map[string]*Color
+									if !d.IsNull() {
+										v2 = []map[string]*Color{}
+										if err := d.ReadArray(func() error {
+											var v3 map[string]*Color
+											if !d.IsNull() {
+												v3 = map[string]*Color{}
+												if err := d.ReadMap(func(k4 string) error {
+													var v4 *Color
+													if !d.IsNull() {
+														v4 = new(Color)
+														if err := v4.UnmarshalDisorder(d); err != nil {
+															return err
+														}
+													}
+													v3[k4] = v4
+													return nil
+												}); err != nil {
+													return err
+												}
+											}
+											v2 = append(v2, v3)
+											return nil
+										}); err != nil {
+											return err
+										}
+									}
+									v1 = append(v1, v2)
+									return nil
+								}); err != nil {
+									return err
+								}
+							}
+							v0[k1] = v1
+							return nil
+						}); err != nil {
+							return err
+						}
+					}
+					m.Nested[k0] = v0
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "long_map":
+			if d.IsNull() {
+				m.LongMap = nil
+			} else {
+				if m.LongMap == nil || d.Mode() == disorder.DecodeReplace {
+					m.LongMap = map[int64]string{}
+				}
+				if err := d.ReadMap(func(k0 string) error {
+					var key0 int64
+					if err := disorder.UnmarshalKey(k0, &key0); err != nil {
+						return err
+					}
+					var v0 string
+					if err := d.ReadString(&v0); err != nil {
+						return err
+					}
+					m.LongMap[key0] = v0
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "enum_map":
+			if d.IsNull() {
+				m.EnumMap = nil
+			} else {
+				if m.EnumMap == nil || d.Mode() == disorder.DecodeReplace {
+					m.EnumMap = map[Color]map[uint16]*sub.NumberWrapper{}
+				}
+				if err := d.ReadMap(func(k0 string) error {
+					var key0 Color
+					if err := disorder.UnmarshalKey(k0, &key0); err != nil {
+						return err
+					}
+					var v0 map[uint16]*sub.NumberWrapper
+					if !d.IsNull() {
+						v0 = map[uint16]*sub.NumberWrapper{}
+						if err := d.ReadMap(func(k1 string) error {
+							var key1 uint16
+							if err := disorder.UnmarshalKey(k1, &key1); err != nil {
+								return err
+							}
+							var v1 *sub.NumberWrapper
+							if !d.IsNull() {
+								v1 = new(sub.NumberWrapper)
+								if err := v1.UnmarshalDisorder(d); err != nil {
+									return err
+								}
+							}
+							v0[key1] = v1
+							return nil
+						}); err != nil {
+							return err
+						}
+					}
+					m.EnumMap[key0] = v0
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		default:
+			return d.SkipUnknown(key, m)
+		}
+		return nil
+	})
 }
 
 type Zero struct {
 	ZeroArray []int32          `disorder:"zero_array" json:"zero_array,omitempty"`
 	ZeroMap   map[string]int32 `disorder:"zero_map" json:"zero_map,omitempty"`
 }
+
+func (m *Zero) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteObject(name, func() error {
+		if m.ZeroArray != nil {
+			if err := e.WriteArray("zero_array", func() error {
+				for _, v0 := range m.ZeroArray {
+					if err := e.WriteInt("", v0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("zero_array"); err != nil {
+			return err
+		}
+		if m.ZeroMap != nil {
+			if err := e.WriteObject("zero_map", func() error {
+				keys0 := make([]string, 0, len(m.ZeroMap))
+				for k0 := range m.ZeroMap {
+					keys0 = append(keys0, k0)
+				}
+				e.SortKeys(keys0)
+				for _, k0 := range keys0 {
+					v0 := m.ZeroMap[k0]
+					if err := e.WriteInt(k0, v0); err != nil {
+						return err
+					}
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("zero_map"); err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func (m *Zero) UnmarshalDisorder(d *disorder.Decoder) error {
+	if d.Mode() == disorder.DecodeReplace {
+		*m = Zero{}
+	}
+	return d.ReadObject(func(key string) error {
+		switch key {
+		case "zero_array":
+			if d.IsNull() {
+				m.ZeroArray = nil
+			} else {
+				if m.ZeroArray == nil || d.Mode() != disorder.DecodeAppend {
+					m.ZeroArray = []int32{}
+				}
+				if err := d.ReadArray(func() error {
+					var v0 int32
+					if err := d.ReadInt(&v0); err != nil {
+						return err
+					}
+					m.ZeroArray = append(m.ZeroArray, v0)
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		case "zero_map":
+			if d.IsNull() {
+				m.ZeroMap = nil
+			} else {
+				if m.ZeroMap == nil || d.Mode() == disorder.DecodeReplace {
+					m.ZeroMap = map[string]int32{}
+				}
+				if err := d.ReadMap(func(k0 string) error {
+					var v0 int32
+					if err := d.ReadInt(&v0); err != nil {
+						return err
+					}
+					m.ZeroMap[k0] = v0
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		default:
+			return d.SkipUnknown(key, m)
+		}
+		return nil
+	})
+}
@@ -1,12 +1,109 @@
 // Code generated by https://github.com/meerkat-io/disorder; DO NOT EDIT.
 package sub
 
-import ()
+import (
+	"github.com/meerkat-io/disorder"
+)
 
 type Number struct {
 	Value int32 `disorder:"value" json:"value"`
+
+	// XXX_unknown keeps the fields missing from the schema for re-encoding.
+	XXX_unknown disorder.Unknown `json:"-"`
+
+	absent [1]bool
+}
+
+// HasValue reports whether value was present in the last decoded data, null included.
+// Messages which were not decoded report every field as present.
+func (m *Number) HasValue() bool {
+	return !m.absent[0]
+}
+
+func (m *Number) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteObject(name, func() error {
+		if err := e.WriteInt("value", m.Value); err != nil {
+			return err
+		}
+		return e.WriteUnknown(m.XXX_unknown)
+	})
+}
+
+func (m *Number) UnmarshalDisorder(d *disorder.Decoder) error {
+	if d.Mode() == disorder.DecodeReplace {
+		*m = Number{}
+	}
+	for i := range m.absent {
+		m.absent[i] = true
+	}
+	m.XXX_unknown = nil
+	return d.ReadObject(func(key string) error {
+		switch key {
+		case "value":
+			m.absent[0] = false
+			if err := d.ReadInt(&m.Value); err != nil {
+				return err
+			}
+		default:
+			return d.ReadUnknown(key, m, &m.XXX_unknown)
+		}
+		return nil
+	})
 }
 
 type NumberWrapper struct {
 	Value *Number `disorder:"value" json:"value,omitempty"`
+
+	// XXX_unknown keeps the fields missing from the schema for re-encoding.
+	XXX_unknown disorder.Unknown `json:"-"`
+
+	absent [1]bool
+}
+
+// HasValue reports whether value was present in the last decoded data, null included.
+// Messages which were not decoded report every field as present.
+func (m *NumberWrapper) HasValue() bool {
+	return !m.absent[0]
+}
+
+func (m *NumberWrapper) MarshalDisorder(e *disorder.Encoder, name string) error {
+	return e.WriteObject(name, func() error {
+		if m.Value != nil {
+			if err := m.Value.MarshalDisorder(e, "value"); err != nil {
+				return err
+			}
+		} else if err := e.WriteNullField("value"); err != nil {
+			return err
+		}
+		return e.WriteUnknown(m.XXX_unknown)
+	})
+}
+
+func (m *NumberWrapper) UnmarshalDisorder(d *disorder.Decoder) error {
+	if d.Mode() == disorder.DecodeReplace {
+		*m = NumberWrapper{}
+	}
+	for i := range m.absent {
+		m.absent[i] = true
+	}
+	m.XXX_unknown = nil
+	return d.ReadObject(func(key string) error {
+		switch key {
+		case "value":
+			m.absent[0] = false
+			if d.IsNull() {
+				m.Value = nil
+			} else {
+				if m.Value == nil {
+					m.Value = new(Number)
+				}
+				if err := m.Value.UnmarshalDisorder(d); err != nil {
+					return err
+				}
+			}
+		default:
+			return d.ReadUnknown(key, m, &m.XXX_unknown)
+		}
+		return nil
+	})
 }
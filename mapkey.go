@@ -0,0 +1,95 @@
+package disorder
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	enumType            = reflect.TypeOf((*Enum)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// MarshalKey formats a map key as an object key. Enums are written by value,
+// encoding.TextMarshaler keys by their text, strings as they are and integers in decimal.
+func MarshalKey(key interface{}) (string, error) {
+	return marshalKey(reflect.ValueOf(key))
+}
+
+// UnmarshalKey parses an object key into the map key pointed to by key, it reverses MarshalKey.
+func UnmarshalKey(name string, key interface{}) error {
+	value := reflect.ValueOf(key)
+	if value.Kind() != reflect.Ptr || value.IsNil() {
+		return fmt.Errorf("map key must be a non nil pointer")
+	}
+	return unmarshalKey(name, value.Elem())
+}
+
+// validKey reports whether values of typ can be map keys.
+func validKey(typ reflect.Type) bool {
+	ptr := reflect.PtrTo(typ)
+	if ptr.Implements(enumType) || ptr.Implements(textMarshalerType) || ptr.Implements(textUnmarshalerType) {
+		return true
+	}
+	switch typ.Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func marshalKey(key reflect.Value) (string, error) {
+	addressable := reflect.New(key.Type())
+	addressable.Elem().Set(key)
+	if enum, ok := addressable.Interface().(Enum); ok {
+		return enum.GetValue()
+	}
+	if marshaler, ok := addressable.Interface().(encoding.TextMarshaler); ok {
+		text, err := marshaler.MarshalText()
+		return string(text), err
+	}
+	switch key.Kind() {
+	case reflect.String:
+		return key.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(key.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(key.Uint(), 10), nil
+	}
+	return "", fmt.Errorf("unsupported map key type %s", key.Type())
+}
+
+// unmarshalKey sets the addressable key from name.
+func unmarshalKey(name string, key reflect.Value) error {
+	if enum, ok := key.Addr().Interface().(Enum); ok {
+		return enum.SetValue(name)
+	}
+	if unmarshaler, ok := key.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return unmarshaler.UnmarshalText([]byte(name))
+	}
+	switch key.Kind() {
+	case reflect.String:
+		key.SetString(name)
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(name, 10, key.Type().Bits())
+		if err != nil {
+			return err
+		}
+		key.SetInt(i)
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(name, 10, key.Type().Bits())
+		if err != nil {
+			return err
+		}
+		key.SetUint(u)
+		return nil
+	}
+	return fmt.Errorf("unsupported map key type %s", key.Type())
+}
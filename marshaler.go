@@ -0,0 +1,90 @@
+package disorder
+
+import (
+	"encoding"
+	"reflect"
+)
+
+// Marshaler is implemented by types that can encode themselves without reflection.
+// The value must be written under the given name, which is empty for array elements
+// and top level values.
+type Marshaler interface {
+	MarshalDisorder(e *Encoder, name string) error
+}
+
+// Unmarshaler is implemented by types that can decode themselves without reflection.
+// The tag of the value has already been consumed when UnmarshalDisorder is called,
+// the Read* methods of the decoder check against it.
+type Unmarshaler interface {
+	UnmarshalDisorder(d *Decoder) error
+}
+
+var (
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	binaryMarshalerType = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+)
+
+func marshaler(value reflect.Value) (Marshaler, bool) {
+	m, ok := methods(value, marshalerType).(Marshaler)
+	return m, ok
+}
+
+func unmarshaler(value reflect.Value) (Unmarshaler, bool) {
+	u, ok := pointerMethods(value).(Unmarshaler)
+	return u, ok
+}
+
+// textMarshaler and binaryMarshaler are the fallbacks of types which are not handled otherwise,
+// a TextMarshaler is written as string and a BinaryMarshaler as bytes.
+func textMarshaler(value reflect.Value) (encoding.TextMarshaler, bool) {
+	m, ok := methods(value, textMarshalerType).(encoding.TextMarshaler)
+	return m, ok
+}
+
+func binaryMarshaler(value reflect.Value) (encoding.BinaryMarshaler, bool) {
+	m, ok := methods(value, binaryMarshalerType).(encoding.BinaryMarshaler)
+	return m, ok
+}
+
+func textUnmarshaler(value reflect.Value) (encoding.TextUnmarshaler, bool) {
+	u, ok := pointerMethods(value).(encoding.TextUnmarshaler)
+	return u, ok
+}
+
+func binaryUnmarshaler(value reflect.Value) (encoding.BinaryUnmarshaler, bool) {
+	u, ok := pointerMethods(value).(encoding.BinaryUnmarshaler)
+	return u, ok
+}
+
+// methods returns value with the methods of its pointer when the pointer implements typ,
+// pointer receivers are used by values too, addressable or not.
+func methods(value reflect.Value, typ reflect.Type) interface{} {
+	if value.Kind() != reflect.Ptr && reflect.PtrTo(value.Type()).Implements(typ) {
+		value = addressable(value)
+	}
+	return value.Interface()
+}
+
+// addressable returns a pointer to value, or to a copy of it when value cannot be addressed.
+func addressable(value reflect.Value) reflect.Value {
+	if value.CanAddr() {
+		return value.Addr()
+	}
+	copied := reflect.New(value.Type())
+	copied.Elem().Set(value)
+	return copied
+}
+
+// pointerMethods returns a non nil pointer to value, or nil when value cannot be addressed.
+func pointerMethods(value reflect.Value) interface{} {
+	if value.Kind() != reflect.Ptr {
+		if !value.CanAddr() {
+			return nil
+		}
+		value = value.Addr()
+	}
+	if value.IsNil() {
+		return nil
+	}
+	return value.Interface()
+}
@@ -0,0 +1,82 @@
+package disorder
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+)
+
+var rawMessageType = reflect.TypeOf(RawMessage(nil))
+
+// RawMessage is an encoded value including its tag, like json.RawMessage.
+// It is captured without decoding and encoded by splicing it in unchanged,
+// only interned keys are written in full.
+type RawMessage []byte
+
+// ReadRaw captures the current value.
+func (d *Decoder) ReadRaw(value *RawMessage) error {
+	raw, err := d.capture(d.current)
+	if err != nil {
+		return err
+	}
+	*value = raw
+	return nil
+}
+
+// WriteRaw writes an encoded value under name, value must hold exactly one complete value.
+func (e *Encoder) WriteRaw(name string, value RawMessage) error {
+	if len(value) == 0 {
+		return fmt.Errorf("empty raw message")
+	}
+	t := tag(value[0])
+	if !valueTag(t) {
+		return fmt.Errorf("raw message starting with %s", t)
+	}
+	err := e.writeKeyHead(t, name)
+	if err != nil {
+		return err
+	}
+	return e.writeRaw(value[1:], func(d *Decoder, size int64) error {
+		d.compact = t&tagCompact != 0
+		return d.skip(t &^ tagCompact)
+	})
+}
+
+// writeRaw splices encoded data, read reads all of it from a decoder over the size bytes of data
+// and the data must not go on after what read consumed.
+// Key references are resolved against the key table of the data itself and written in full,
+// since the stream the data is spliced into has a table of its own. When keys are interned the keys
+// written in full by the data are added to the key table, as the decoder reading the stream does.
+func (e *Encoder) writeRaw(raw []byte, read func(d *Decoder, size int64) error) error {
+	if e.err != nil {
+		return e.err
+	}
+	d := NewDecoder(bytes.NewReader(raw))
+	d.capturing = true
+	err := read(d, int64(len(raw)))
+	if err != nil {
+		return err
+	}
+	if d.offset != int64(len(raw)) {
+		return fmt.Errorf("raw message has %d bytes after its value", int64(len(raw))-d.offset)
+	}
+	raw = d.captured
+	if e.options.InternKeys {
+		d = NewDecoder(bytes.NewReader(raw))
+		err = read(d, int64(len(raw)))
+		if err != nil {
+			return err
+		}
+		for _, key := range d.keys {
+			e.addKey(key)
+		}
+	}
+	if len(e.buffer) >= bufferSize {
+		err := e.Flush()
+		if err != nil {
+			return err
+		}
+	}
+	e.buffer = append(e.buffer, raw...)
+	return nil
+}
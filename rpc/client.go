@@ -23,6 +23,7 @@ type Client struct {
 	b            Balancer
 	service      string
 	interceptors []ClientInterceptor
+	options      disorder.EncoderOptions
 }
 
 func NewClient(addr, service string) *Client {
@@ -41,6 +42,12 @@ func NewClientWithBalancer(b Balancer, service string) *Client {
 	}
 }
 
+// SetEncoderOptions sets the options of the requests. Servers older than interning
+// cannot decode requests written with InternKeys.
+func (c *Client) SetEncoderOptions(options disorder.EncoderOptions) {
+	c.options = options
+}
+
 func (c *Client) AddInterceptor(interceptor ClientInterceptor) {
 	c.interceptors = append(c.interceptors, interceptor)
 }
@@ -64,7 +71,7 @@ func (c *Client) Send(method string, request interface{}, response interface{})
 	defer conn.Close()
 
 	// write
-	e := disorder.NewEncoder(conn.Writer())
+	e := disorder.NewEncoder(conn.Writer(), c.options)
 	context := NewContext()
 	err = context.writeRpcInfo(c.service, method)
 	if err != nil {
@@ -91,6 +98,13 @@ func (c *Client) Send(method string, request interface{}, response interface{})
 			Error: err,
 		}
 	}
+	err = e.Flush()
+	if err != nil {
+		return &Error{
+			Code:  code.NetworkDisconnected,
+			Error: err,
+		}
+	}
 
 	// read
 	d := disorder.NewDecoder(conn.Reader())
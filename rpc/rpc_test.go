@@ -1,6 +1,7 @@
 package rpc_test
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"testing"
@@ -63,6 +64,50 @@ func TestRpc(t *testing.T) {
 	s.Close()
 }
 
+func TestInternKeys(t *testing.T) {
+	s := rpc.NewServer()
+	s.SetEncoderOptions(disorder.EncoderOptions{InternKeys: true})
+	RegisterTestService(s, &TestServiceImpl{})
+	err := s.Listen(":9999")
+	assert.Nil(t, err)
+
+	client := rpc.NewClient("localhost:9999", "test")
+	client.SetEncoderOptions(disorder.EncoderOptions{InternKeys: true})
+	c := &TestServiceClient{client: client}
+	request := &Object{
+		IntArray: []int32{1, 2, 3},
+		ObjArray: []*NumberWrapper{{Value: &Number{Value: 1}}, {Value: &Number{Value: 2}}},
+	}
+	response, rpcErr := c.Reflect(request)
+	assert.Nil(t, rpcErr)
+	assert.Equal(t, request, response)
+
+	s.Close()
+}
+
+func TestDecoderLimits(t *testing.T) {
+	s := rpc.NewServer()
+	RegisterTestService(s, &TestServiceImpl{})
+	err := s.Listen(":9999")
+	assert.Nil(t, err)
+
+	client := rpc.NewClient("localhost:9999", "test")
+	var nested interface{} = int32(1)
+	for i := 0; i < rpc.DefaultDecoderOptions.MaxDepth; i++ {
+		nested = []interface{}{nested}
+	}
+	var response Object
+	rpcErr := client.Send("reflect", map[string]interface{}{"nested_arrays": nested}, &response)
+	assert.NotNil(t, rpcErr)
+	assert.Equal(t, code.ResourceExhausted, rpcErr.Code)
+
+	nested = nested.([]interface{})[0]
+	rpcErr = client.Send("reflect", map[string]interface{}{"nested_arrays": nested}, &response)
+	assert.Nil(t, rpcErr)
+
+	s.Close()
+}
+
 func TestInterceptor(t *testing.T) {
 	s := rpc.NewServer()
 	RegisterTestService(s, &TestServiceImpl{})
@@ -104,8 +149,15 @@ func (h *testServiceHandler) Increase(d *disorder.Decoder) (interface{}, *rpc.Er
 	var request int32
 	err := d.Decode(&request)
 	if err != nil {
+		if errors.Is(err, disorder.ErrLimitExceeded) {
+			return nil, &rpc.Error{
+				Code:  code.ResourceExhausted,
+				Error: err,
+			}
+		}
 		return nil, &rpc.Error{
-			Code: code.InvalidRequest,
+			Code:  code.InvalidRequest,
+			Error: err,
 		}
 	}
 	return h.service.Increase(request)
@@ -115,8 +167,15 @@ func (h *testServiceHandler) Relect(d *disorder.Decoder) (interface{}, *rpc.Erro
 	var request Object
 	err := d.Decode(&request)
 	if err != nil {
+		if errors.Is(err, disorder.ErrLimitExceeded) {
+			return nil, &rpc.Error{
+				Code:  code.ResourceExhausted,
+				Error: err,
+			}
+		}
 		return nil, &rpc.Error{
-			Code: code.InvalidRequest,
+			Code:  code.InvalidRequest,
+			Error: err,
 		}
 	}
 	return h.service.Relect(&request)
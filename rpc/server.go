@@ -1,6 +1,7 @@
 package rpc
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/meerkat-io/bloom/tcp"
@@ -10,19 +11,42 @@ import (
 
 type Handler func(decoder *disorder.Decoder) (interface{}, *Error)
 
+// DefaultDecoderOptions bounds the resources a single request can take on the server.
+var DefaultDecoderOptions = disorder.DecoderOptions{
+	MaxBytesLength: 16 << 20,
+	MaxDepth:       64,
+	MaxElements:    1 << 20,
+	MaxTotalBytes:  64 << 20,
+}
+
 type Server struct {
-	listener     *tcp.Listener
-	handlers     map[string]map[string]Handler
-	interceptors map[string][]ServerInterceptor
+	listener       *tcp.Listener
+	handlers       map[string]map[string]Handler
+	interceptors   map[string][]ServerInterceptor
+	decoderOptions disorder.DecoderOptions
+	encoderOptions disorder.EncoderOptions
 }
 
 func NewServer() *Server {
 	return &Server{
-		handlers:     make(map[string]map[string]Handler),
-		interceptors: make(map[string][]ServerInterceptor),
+		handlers:       make(map[string]map[string]Handler),
+		interceptors:   make(map[string][]ServerInterceptor),
+		decoderOptions: DefaultDecoderOptions,
 	}
 }
 
+// SetDecoderOptions sets the limits of the requests, headers and bodies alike. Requests
+// going over a limit are answered with code.ResourceExhausted.
+func (s *Server) SetDecoderOptions(options disorder.DecoderOptions) {
+	s.decoderOptions = options
+}
+
+// SetEncoderOptions sets the options of the responses. Clients older than interning
+// cannot decode responses written with InternKeys.
+func (s *Server) SetEncoderOptions(options disorder.EncoderOptions) {
+	s.encoderOptions = options
+}
+
 func (s *Server) Listen(addr string) error {
 	l, err := tcp.Listen(addr, s)
 	s.listener = l
@@ -48,11 +72,15 @@ func (s *Server) Accept(conn *tcp.Connection) {
 	defer conn.Close()
 
 	// read
-	d := disorder.NewDecoder(conn.Reader())
+	d := disorder.NewDecoder(conn.Reader(), s.decoderOptions)
 	context := NewContext()
 	err := d.Decode(&context.headers)
 	if err != nil {
-		s.sendError(conn, code.InvalidRequest, err)
+		if errors.Is(err, disorder.ErrLimitExceeded) {
+			s.sendError(conn, code.ResourceExhausted, err)
+		} else {
+			s.sendError(conn, code.InvalidRequest, err)
+		}
 		return
 	}
 	service, method, err := context.readRpcInfo()
@@ -115,11 +143,13 @@ func (s *Server) sendError(conn *tcp.Connection, code code.Code, err error) {
 	context := NewContext()
 	context.writeError(code, err)
 	e := disorder.NewEncoder(conn.Writer())
-	_ = e.Encode(context.headers)
+	if e.Encode(context.headers) == nil {
+		_ = e.Flush()
+	}
 }
 
 func (s *Server) sendResponse(conn *tcp.Connection, response interface{}) *Error {
-	e := disorder.NewEncoder(conn.Writer())
+	e := disorder.NewEncoder(conn.Writer(), s.encoderOptions)
 	err := e.Encode(map[string]string{})
 	if err != nil {
 		return &Error{
@@ -134,5 +164,12 @@ func (s *Server) sendResponse(conn *tcp.Connection, response interface{}) *Error
 			Error: err,
 		}
 	}
+	err = e.Flush()
+	if err != nil {
+		return &Error{
+			Code:  code.NetworkDisconnected,
+			Error: err,
+		}
+	}
 	return nil
 }
@@ -15,13 +15,27 @@ var (
 type structInfo struct {
 	fieldsMap  map[string]*fieldInfo
 	fieldsList []*fieldInfo
+	required   []*fieldInfo
+	// unknown is the index of the Unknown field, nil if there is none.
+	unknown []int
 }
 
 type fieldInfo struct {
 	key   string
-	index int
+	index []int
+	// tagged is set when the key comes from the tag, it wins conflicts at the same depth.
+	tagged bool
+	// omitEmpty leaves out false, 0, "", empty collections and nil values.
+	omitEmpty bool
+	// required fails decoding when the key is missing, a nil value is written as null.
+	required bool
+	// quoted encodes bools and numbers as strings.
+	quoted bool
 }
 
+// getStructInfo resolves the fields of a struct with the encoding/json rules: fields of embedded
+// and inline structs are promoted, a field shadows the deeper fields with the same key, and
+// conflicting promoted fields at the same depth are dropped unless exactly one of them is tagged.
 func getStructInfo(typ reflect.Type) (*structInfo, error) {
 	structsMapMutex.RLock()
 	s, exists := structsMap[typ]
@@ -30,19 +44,102 @@ func getStructInfo(typ reflect.Type) (*structInfo, error) {
 		return s, nil
 	}
 
-	count := typ.NumField()
+	fields, err := collectFields(typ, nil, map[reflect.Type]bool{})
+	if err != nil {
+		return nil, err
+	}
+	candidates := map[string][]*fieldInfo{}
+	for _, f := range fields {
+		candidates[f.key] = append(candidates[f.key], f)
+	}
 	s = &structInfo{
 		fieldsMap:  map[string]*fieldInfo{},
-		fieldsList: make([]*fieldInfo, 0, count),
+		fieldsList: make([]*fieldInfo, 0, len(candidates)),
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if field := typ.Field(i); field.Type == unknownType && field.PkgPath == "" {
+			s.unknown = field.Index
+		}
 	}
+	for _, f := range fields {
+		dominant, err := dominantField(typ, candidates[f.key])
+		if err != nil {
+			return nil, err
+		}
+		if dominant != f {
+			continue
+		}
+		s.fieldsList = append(s.fieldsList, f)
+		s.fieldsMap[f.key] = f
+		if f.required {
+			s.required = append(s.required, f)
+		}
+	}
+	structsMapMutex.Lock()
+	defer structsMapMutex.Unlock()
+	structsMap[typ] = s
+	return s, nil
+}
+
+// dominantField returns the field winning among the fields with the same key, or nil if none does.
+func dominantField(typ reflect.Type, fields []*fieldInfo) (*fieldInfo, error) {
+	depth := len(fields[0].index)
+	for _, f := range fields[1:] {
+		if len(f.index) < depth {
+			depth = len(f.index)
+		}
+	}
+	var dominant *fieldInfo
+	count, tagged := 0, 0
+	for _, f := range fields {
+		if len(f.index) != depth {
+			continue
+		}
+		count++
+		if f.tagged {
+			tagged++
+			dominant = f
+		} else if dominant == nil {
+			dominant = f
+		}
+	}
+	switch {
+	case count == 1:
+		return dominant, nil
+	case depth == 1:
+		return nil, fmt.Errorf("duplicated key '" + dominant.key + "' in struct " + typ.String())
+	case tagged == 1:
+		return dominant, nil
+	default:
+		return nil, nil
+	}
+}
+
+// collectFields returns the fields of typ, the fields of embedded and inline structs are collected in their place.
+func collectFields(typ reflect.Type, index []int, inlining map[reflect.Type]bool) ([]*fieldInfo, error) {
+	inlining[typ] = true
+	defer delete(inlining, typ)
+	count := typ.NumField()
+	fields := make([]*fieldInfo, 0, count)
 	for i := 0; i < count; i++ {
 		field := typ.Field(i)
-		if field.PkgPath != "" && !field.Anonymous {
+		if field.Type == unknownType {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous {
+			if field.PkgPath != "" && (field.Type.Kind() == reflect.Ptr || fieldType.Kind() != reflect.Struct) {
+				continue // Private embedded field which cannot be set or promoted
+			}
+		} else if field.PkgPath != "" {
 			continue // Private field
 		}
 
 		f := &fieldInfo{
-			index: i,
+			index: append(append([]int{}, index...), i),
 		}
 		tag := field.Tag.Get("disorder")
 		if tag == "" && !strings.Contains(string(field.Tag), ":") {
@@ -51,41 +148,116 @@ func getStructInfo(typ reflect.Type) (*structInfo, error) {
 		if tag == "-" {
 			continue
 		}
-		fields := strings.Split(tag, ",")
-		if len(fields) > 0 {
-			tag = fields[0]
-			if len(fields) > 1 {
-				return nil, fmt.Errorf("unsupported flag %s in tag %s", fields[1], tag)
+		options := strings.Split(tag, ",")
+		tag = options[0]
+		inline := false
+		for _, option := range options[1:] {
+			switch option {
+			case "omitempty":
+				f.omitEmpty = true
+			case "required":
+				f.required = true
+			case "inline":
+				inline = true
+			case "string":
+				f.quoted = quotable(field.Type)
+			default:
+				return nil, fmt.Errorf("unsupported flag %s in tag %s", option, tag)
 			}
 		}
+
+		if inline || (field.Anonymous && tag == "" && fieldType.Kind() == reflect.Struct) {
+			if fieldType.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("inline field %s in struct %s is not a struct", field.Name, typ)
+			}
+			if inlining[fieldType] {
+				if inline {
+					return nil, fmt.Errorf("recursive inline field %s in struct %s", field.Name, typ)
+				}
+				continue // Embedded by itself, nothing new to promote
+			}
+			inlined, err := collectFields(fieldType, f.index, inlining)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, inlined...)
+			continue
+		}
+
 		if tag != "" {
 			f.key = tag
+			f.tagged = true
 		} else {
 			f.key = field.Name
 		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
 
-		if _, exists = s.fieldsMap[f.key]; exists {
-			return nil, fmt.Errorf("duplicated key '" + f.key + "' in struct " + typ.String())
+// quotable reports whether the string option applies to typ.
+func quotable(typ reflect.Type) bool {
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
+// fieldByIndex returns the nested field of a struct value, nil pointers on the way are
+// allocated if alloc is set, otherwise an invalid value is returned.
+func fieldByIndex(value reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Ptr {
+			if value.IsNil() {
+				if !alloc {
+					return reflect.Value{}
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
 		}
+		value = value.Field(x)
+	}
+	return value
+}
 
-		s.fieldsList = append(s.fieldsList, f)
-		s.fieldsMap[f.key] = f
+// isEmpty reports whether value is left out by the omitempty option, like encoding/json does.
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return value.Len() == 0
+	case reflect.Bool:
+		return !value.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return value.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return value.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return value.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil()
 	}
-	structsMapMutex.Lock()
-	defer structsMapMutex.Unlock()
-	structsMap[typ] = s
-	return s, nil
+	return false
 }
 
+// isNull reports whether value is invalid or nil, interfaces and pointers are followed to the value they hold.
 func isNull(value reflect.Value) bool {
 	if !value.IsValid() {
 		return true
 	}
 	switch value.Kind() {
-	case reflect.Interface, reflect.Ptr, reflect.Slice, reflect.Map:
-		if value.IsNil() {
-			return true
-		}
+	case reflect.Interface, reflect.Ptr:
+		return value.IsNil() || isNull(value.Elem())
+	case reflect.Slice, reflect.Map:
+		return value.IsNil()
 	}
 	return false
 }
@@ -1,5 +1,10 @@
 package disorder
 
+import (
+	"fmt"
+	"reflect"
+)
+
 type tag byte
 
 const (
@@ -11,13 +16,121 @@ const (
 	tagFloat  tag = 4
 	tagDouble tag = 5
 	tagBytes  tag = 6
+	tagUint   tag = 7
+	tagUlong  tag = 8
+	tagShort  tag = 9
+	tagUshort tag = 10
 
 	tagString    tag = 11
 	tagTimestamp tag = 12
 	tagEnum      tag = 13
 
+	tagTimestampNs tag = 14
+	tagDuration    tag = 15
+	tagNull        tag = 16
+
 	tagArrayStart  tag = 21
 	tagArrayEnd    tag = 22
 	tagObjectStart tag = 23
 	tagObjectEnd   tag = 24
+
+	// tagKeyRef replaces the key of an object field by a varint index into the key table,
+	// it is followed by the tag of the value.
+	tagKeyRef tag = 25
+
+	// tagCompact is set on integer, timestamp, bytes and string tags whose integer
+	// payload or length is written as a LEB128 varint, zigzag encoded for signed values.
+	tagCompact tag = 0x80
 )
+
+var tagNames = map[tag]string{
+	tagBool:        "bool",
+	tagInt:         "int",
+	tagLong:        "long",
+	tagFloat:       "float",
+	tagDouble:      "double",
+	tagBytes:       "bytes",
+	tagUint:        "uint",
+	tagUlong:       "ulong",
+	tagShort:       "short",
+	tagUshort:      "ushort",
+	tagString:      "string",
+	tagTimestamp:   "timestamp",
+	tagEnum:        "enum",
+	tagTimestampNs: "timestamp_ns",
+	tagDuration:    "duration",
+	tagNull:        "null",
+	tagArrayStart:  "array",
+	tagArrayEnd:    "array end",
+	tagObjectStart: "object",
+	tagObjectEnd:   "object end",
+	tagKeyRef:      "key reference",
+}
+
+func (t tag) String() string {
+	if t&tagCompact != 0 {
+		return "compact " + (t &^ tagCompact).String()
+	}
+	if name, ok := tagNames[t]; ok {
+		return name
+	}
+	return fmt.Sprintf("tag(%d)", byte(t))
+}
+
+// integerTag returns the tag an integer kind is encoded with, the narrowest wire type holding all its values.
+func integerTag(kind reflect.Kind) tag {
+	switch kind {
+	case reflect.Int8, reflect.Int16:
+		return tagShort
+	case reflect.Uint8, reflect.Uint16:
+		return tagUshort
+	case reflect.Int32:
+		return tagInt
+	case reflect.Uint32:
+		return tagUint
+	case reflect.Int, reflect.Int64:
+		return tagLong
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return tagUlong
+	default:
+		return tagUndefined
+	}
+}
+
+func floatTag(kind reflect.Kind) tag {
+	switch kind {
+	case reflect.Float32:
+		return tagFloat
+	case reflect.Float64:
+		return tagDouble
+	default:
+		return tagUndefined
+	}
+}
+
+// valueTag reports whether tag t starts a value, in its compact form if it has one.
+func valueTag(t tag) bool {
+	if t&tagCompact != 0 {
+		return compactable(t &^ tagCompact)
+	}
+	_, ok := tagNames[t]
+	return ok && t != tagArrayEnd && t != tagObjectEnd && t != tagKeyRef
+}
+
+// compactable reports whether tag t has a compact form.
+func compactable(t tag) bool {
+	switch t {
+	case tagInt, tagLong, tagUint, tagUlong, tagShort, tagUshort, tagTimestamp, tagTimestampNs, tagDuration, tagBytes, tagString:
+		return true
+	default:
+		return false
+	}
+}
+
+func zigzag(i int64) uint64 {
+	return uint64(i<<1) ^ uint64(i>>63)
+}
+
+func unzigzag(u uint64) int64 {
+	return int64(u>>1) ^ -int64(u&1)
+}
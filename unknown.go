@@ -0,0 +1,61 @@
+package disorder
+
+import "reflect"
+
+var unknownType = reflect.TypeOf(Unknown(nil))
+
+// Unknown holds the object fields missing from a struct, encoded as they were read.
+// A struct field of type Unknown collects them on decode and the encoder writes them back,
+// so data passing through an older struct keeps the fields of newer producers.
+type Unknown []byte
+
+// ReadUnknown appends the current field of the struct value to unknown. The field is reported
+// like the ones discarded by SkipUnknown, so DisallowUnknownFields rejects it.
+func (d *Decoder) ReadUnknown(key string, value interface{}, unknown *Unknown) error {
+	typ := reflect.TypeOf(value)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return d.readUnknown(typ, key, unknown)
+}
+
+func (d *Decoder) readUnknown(typ reflect.Type, key string, unknown *Unknown) error {
+	err := d.unknownField(typ, key)
+	if err != nil {
+		return err
+	}
+	raw, err := d.capture(d.current)
+	if err != nil {
+		return err
+	}
+	field := make([]byte, 0, len(raw)+len(key)+1)
+	field = append(field, raw[0], byte(len(key)))
+	field = append(field, key...)
+	field = append(field, raw[1:]...)
+	*unknown = append(*unknown, field...)
+	return nil
+}
+
+// WriteUnknown writes the fields collected by ReadUnknown into the current object.
+func (e *Encoder) WriteUnknown(unknown Unknown) error {
+	if len(unknown) == 0 {
+		return e.err
+	}
+	return e.writeRaw(unknown, func(d *Decoder, size int64) error {
+		for d.offset < size {
+			t, err := d.readTag()
+			if err != nil {
+				return err
+			}
+			_, t, err = d.readKey(t)
+			if err != nil {
+				return err
+			}
+			err = d.skip(t)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}